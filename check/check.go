@@ -1,6 +1,7 @@
 package check
 
 import (
+	"checkData/db/cross"
 	"checkData/db/doris"
 	"checkData/db/mongo"
 	"checkData/db/mssql"
@@ -15,21 +16,43 @@ import (
 	"os"
 	"strings"
 	"sync"
+	"time"
 )
 
 func Start(opt *model.Options) {
 	util.EnterWorkDir()
-	err := util.Mkdir(opt.BaseDir)
+
+	//两端必须使用同一种摘要算法
+	_, err := util.NewHashFunc(opt.Hash)
+	if err != nil {
+		slog.Error(err)
+		os.Exit(1)
+	}
+
+	err = util.Mkdir(opt.BaseDir)
 	if err != nil {
 		slog.Error(err)
 		os.Exit(1)
 	}
 
+	var reports []*model.Report
 	for _, group := range opt.DbGroupList {
-		checkAndSettle(opt, group)
+		reports = append(reports, checkAndSettle(opt, group))
+	}
+
+	//一次运行的所有数据库写入一个html报告
+	if opt.HtmlReport {
+		htmlFile := fmt.Sprintf("%s/report.html", opt.BaseDir)
+		if err := saveHtmlReport(htmlFile, reports); err != nil {
+			slog.Error(err)
+		} else {
+			slog.Infof("html报告：%s", htmlFile)
+		}
 	}
 }
-func checkAndSettle(opt *model.Options, dbg [2]string) {
+
+func checkAndSettle(opt *model.Options, dbg [2]string) *model.Report {
+	start := time.Now()
 	dirName := fmt.Sprintf("%s/%s", opt.BaseDir, dbg[1])
 	err := util.Mkdir(dirName)
 	if err != nil {
@@ -37,13 +60,27 @@ func checkAndSettle(opt *model.Options, dbg [2]string) {
 		os.Exit(1)
 	}
 
-	//创建新的文件
+	//创建新的文件，断点续核时追加到原有的文件
 	csvFile := fmt.Sprintf("%s/%s.csv", opt.BaseDir, dbg[1])
 	fieldNames := "DbName,TableName,Status,ExecuteSeconds,SourceRows,TargetRows,SameRows,DiffRows,SourceMoreRows,TargetMoreRows,RecheckPassRows,Message\n"
-	util.WriteFile(csvFile, fieldNames)
+	if !opt.Resume || !util.FileExists(csvFile) {
+		util.WriteFile(csvFile, fieldNames)
+	}
+	jsonlFile := fmt.Sprintf("%s/%s.jsonl", opt.BaseDir, dbg[1])
+	if !opt.Resume {
+		util.WriteFile(jsonlFile, "")
+	}
+
+	//断点文件
+	cp, err := OpenCheckpoint(fmt.Sprintf("%s/%s.checkpoint", opt.BaseDir, dbg[1]), opt.Resume)
+	if err != nil {
+		slog.Error(err)
+		os.Exit(1)
+	}
+	defer cp.Close()
 
 	//核对数据库
-	tables, results := checkDB(opt, dbg)
+	tables, results := checkDB(opt, dbg, cp)
 
 	//汇总结果
 	var yesTables, noTables, unknownTables []string
@@ -65,6 +102,7 @@ func checkAndSettle(opt *model.Options, dbg [2]string) {
 	buf.WriteString("核对文件说明\n")
 	buf.WriteString("rpt文件: 核对总览信息\n")
 	buf.WriteString("csv文件: 核对明细信息\n")
+	buf.WriteString("json/jsonl文件: json格式的核对报告和每个表的核对结果\n")
 	buf.WriteString("ExecuteSeconds  : 执行时间，包括复核的时间（秒）\n")
 	buf.WriteString("SourceRows      : 源表总行数\n")
 	buf.WriteString("TargetRows      : 目标表总行数\n")
@@ -86,11 +124,45 @@ func checkAndSettle(opt *model.Options, dbg [2]string) {
 	buf.WriteString(fmt.Sprintf("核对失败的表     : %s\n", strings.Join(unknownTables, ", ")))
 	buf.WriteString("####################################################################################################\n")
 
+	//复核后仍不一致的列按模式分类
+	var patterns strings.Builder
+	for _, v := range results {
+		for _, p := range v.DiffPatterns {
+			patterns.WriteString(fmt.Sprintf("%s.%s\n", v.TbName, p))
+		}
+	}
+	if patterns.Len() > 0 {
+		buf.WriteString("########################################## 不一致原因分析 ##########################################\n")
+		buf.WriteString(patterns.String())
+		buf.WriteString("####################################################################################################\n")
+	}
+
+	//schema模式输出每个表的结构差异
+	if opt.Mode == "schema" {
+		buf.WriteString("########################################## 表结构差异 ##############################################\n")
+		for _, v := range results {
+			for _, d := range v.SchemaDiffs {
+				buf.WriteString(fmt.Sprintf("%s : %s\n", v.TbName, d))
+			}
+		}
+		if opt.AlterDDL {
+			buf.WriteString(fmt.Sprintf("使目标端和源端一致的DDL保存在: ./%s/%s/$table.alter.sql\n", opt.BaseDir, dbg[1]))
+		}
+		buf.WriteString("####################################################################################################\n")
+	}
+
 	util.WriteFile(reportFile, buf.String())
 
+	//json报告，供其他程序读取
+	report := newReport(opt, dbg, start, tables, results)
+	jsonFile := fmt.Sprintf("%s/%s.json", opt.BaseDir, dbg[1])
+	if err := saveJsonReport(jsonFile, report); err != nil {
+		slog.Error(err)
+	}
+	return report
 }
 
-func checkDB(opt *model.Options, dbg [2]string) (tables *model.TableInfo, results []*model.Result) {
+func checkDB(opt *model.Options, dbg [2]string, cp *Checkpoint) (tables *model.TableInfo, results []*model.Result) {
 
 	defer util.TimeCost()(fmt.Sprintf("[%s:%s] 数据库核对完成", dbg[0], dbg[1]))
 	slog.Infof("[%s:%s] 开始核对数据库", dbg[0], dbg[1])
@@ -111,6 +183,8 @@ func checkDB(opt *model.Options, dbg [2]string) (tables *model.TableInfo, result
 		db, err = mssql.NewDatabase(opt, dbg)
 	case "oceanbase":
 		db, err = oceanbase.NewDatabase(opt, dbg)
+	case "cross":
+		db, err = cross.NewDatabase(opt, dbg)
 
 	case "default":
 		slog.Errorf("不支持的数据库类型:%s", opt.DbType)
@@ -137,8 +211,18 @@ func checkDB(opt *model.Options, dbg [2]string) (tables *model.TableInfo, result
 
 	mu := &sync.Mutex{}
 	for _, tbName := range tables.ToCheck {
+		if result := cp.GetTable(tbName); result != nil {
+			slog.Infof("[%s.%s] 已核对，跳过", dbg[1], tbName)
+			//队列中的任务同时在追加结果
+			mu.Lock()
+			results = append(results, result)
+			mu.Unlock()
+			continue
+		}
+
 		tb := db.NewTable(tbName)
-		chk := NewChecker(tb, opt)
+		chk := NewChecker(tb, opt.ForTable(tbName)) //配置文件中可以按表指定chunk-size
+		chk.Checkpoint = cp
 
 		pool.AddTask(
 			func() {
@@ -146,6 +230,7 @@ func checkDB(opt *model.Options, dbg [2]string) (tables *model.TableInfo, result
 				mu.Lock()
 				defer mu.Unlock()
 				chk.SaveResult()
+				cp.SaveTable(chk.Result)
 				results = append(results, chk.Result)
 			})
 	}
@@ -3,8 +3,10 @@ package check
 import (
 	"checkData/model"
 	"checkData/util"
+	"encoding/csv"
 	"fmt"
 	"github.com/gookit/slog"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -21,11 +23,12 @@ type Checker struct {
 	TargetDataChan chan *model.Data
 	SourceDoneChan chan struct{}
 	TargetDoneChan chan struct{}
-	SourceMore     map[string]uint32
-	TargetMore     map[string]uint32
-	Diff           []string
+	SourceMore     map[model.Key]string
+	TargetMore     map[model.Key]string
+	Diff           []model.Key
 	Result         *model.Result
 	Options        *model.Options
+	Checkpoint     *Checkpoint //断点文件，nil表示不记录
 }
 
 func NewChecker(t model.Table, opt *model.Options) *Checker {
@@ -34,9 +37,9 @@ func NewChecker(t model.Table, opt *model.Options) *Checker {
 	sDone := make(chan struct{}, 2)
 	tDone := make(chan struct{}, 2)
 
-	source := make(map[string]uint32, opt.Capacity)
-	target := make(map[string]uint32, opt.Capacity)
-	diff := make([]string, 0, opt.Capacity)
+	source := make(map[model.Key]string, opt.Capacity)
+	target := make(map[model.Key]string, opt.Capacity)
+	diff := make([]model.Key, 0, opt.Capacity)
 	return &Checker{
 		Table:          t,
 		Capacity:       opt.Capacity,
@@ -56,7 +59,7 @@ func (self *Checker) AddSame() {
 	self.Result.SameRows++
 }
 
-func (self *Checker) AddDiff(key string) int {
+func (self *Checker) AddDiff(key model.Key) int {
 	self.Diff = append(self.Diff, key)
 	if len(self.Diff) >= self.Capacity {
 		return -2
@@ -64,7 +67,7 @@ func (self *Checker) AddDiff(key string) int {
 	return 0
 }
 
-func (self *Checker) AddSourceMore(key string, val uint32) int {
+func (self *Checker) AddSourceMore(key model.Key, val string) int {
 	/* 返回值说明
 	   -2:容量已满  -1:未找到  0:值不一致  1:值一致
 	*/
@@ -89,7 +92,7 @@ func (self *Checker) AddSourceMore(key string, val uint32) int {
 	}
 }
 
-func (self *Checker) AddTargetMore(key string, val uint32) int {
+func (self *Checker) AddTargetMore(key model.Key, val string) int {
 	/* 返回值说明
 	   -2:容量已满  -1:未找到  0:值不一致  1:值一致
 	*/
@@ -277,22 +280,22 @@ func (self *Checker) Recheck() {
 
 	defer util.TimeCost()(fmt.Sprintf("[%s.%s] 表明细数据复核完成", self.Table.GetDbName(), self.Table.GetTbName()))
 	slog.Infof("[%s.%s] 开始复核表明细数据", self.Table.GetDbName(), self.Table.GetTbName())
-	idTextList := make([]string, 0)
-	recheckPassList := make([]string, 0)
-	for _, idText := range self.Diff {
-		idTextList = append(idTextList, idText)
+	ids := make([]model.Key, 0)
+	recheckPassList := make([]model.Key, 0)
+	for _, id := range self.Diff {
+		ids = append(ids, id)
 	}
 
-	for idText, _ := range self.SourceMore {
-		idTextList = append(idTextList, idText)
+	for id, _ := range self.SourceMore {
+		ids = append(ids, id)
 	}
 
-	for idText, _ := range self.TargetMore {
-		idTextList = append(idTextList, idText)
+	for id, _ := range self.TargetMore {
+		ids = append(ids, id)
 	}
 
 	for i := 1; i <= self.Options.MaxRecheckTimes; i++ {
-		if len(idTextList) == 0 {
+		if len(ids) == 0 {
 			break
 		}
 
@@ -301,9 +304,9 @@ func (self *Checker) Recheck() {
 		}
 
 		slog.Infof("[%s.%s] 第 %d 次复核开始", self.Table.GetDbName(), self.Table.GetTbName(), i)
-		passList := self.Table.Recheck(idTextList)
+		passList := self.Table.Recheck(ids)
 		if len(passList) > 0 {
-			util.RemoveSliceMultiElement(&idTextList, &passList) //剔除复核通过的记录
+			util.RemoveSliceMultiElement(&ids, &passList) //剔除复核通过的记录
 			recheckPassList = append(recheckPassList, passList...)
 		}
 
@@ -329,6 +332,13 @@ func (self *Checker) Start() {
 	slog.Infof("[%s.%s] 开始核对", self.Table.GetDbName(), self.Table.GetTbName())
 	t := time.Now()
 
+	//schema模式只核对表结构，没有主键的表也可以核对，不需要预检查
+	if self.Options.Mode == "schema" {
+		self.CheckSchema()
+		self.Result.ExecuteSeconds = int(time.Since(t).Seconds())
+		return
+	}
+
 	if !self.Table.PreCheck() {
 		slog.Errorf("[%s.%s] 预检查不通过", self.Table.GetDbName(), self.Table.GetTbName())
 		return
@@ -337,7 +347,11 @@ func (self *Checker) Start() {
 	if self.Options.Mode == "count" {
 		self.CheckCount()
 	} else {
-		self.CheckDetail()
+		if self.Options.ChunkSize > 0 {
+			self.CheckChunks()
+		} else {
+			self.CheckDetail()
+		}
 		self.Recheck()
 	}
 
@@ -350,6 +364,12 @@ func (self *Checker) Start() {
 		//导出修复SQL
 		if self.Result.RecheckPassRows != -1 {
 			self.SaveRepairSQL()
+			self.SaveDiffDetail()
+		}
+
+		//html报告中展示的样本
+		if self.Options.HtmlReport && self.Result.Status == 0 {
+			self.collectSamples()
 		}
 	}
 
@@ -380,46 +400,51 @@ func (self *Checker) settle() {
 func (self *Checker) SaveRepairSQL() {
 	//defer util.TimeCost()(fmt.Sprintf("[%s.%s] 保存修复SQL完成", self.Table.GetDbName(), self.Table.GetTbName()))
 
+	ext := "sql"
+	if tb, ok := self.Table.(model.RepairScriptTable); ok {
+		ext = tb.GetRepairFileExt()
+	}
+
 	var sqlText strings.Builder
 	if len(self.TargetMore) > 0 {
 		sqlText.Reset()
-		for idText, _ := range self.TargetMore {
-			_sql, err := self.Table.GetRepairSQL(idText, -1)
+		for id, _ := range self.TargetMore {
+			_sql, err := self.Table.GetRepairSQL(id, -1)
 			if err != nil {
-				slog.Errorf("[%s.%s] 导出delete.sql文件报错: %s", self.Table.GetDbName(), self.Table.GetTbName(), err)
+				slog.Errorf("[%s.%s] 导出delete.%s文件报错: %s", self.Table.GetDbName(), self.Table.GetTbName(), ext, err)
 			} else {
 				sqlText.WriteString(_sql)
 			}
 		}
-		deleteFile := fmt.Sprintf("%s/%s/%s.delete.sql", self.Options.BaseDir, self.Table.GetDbName(), self.Table.GetTbName())
+		deleteFile := fmt.Sprintf("%s/%s/%s.delete.%s", self.Options.BaseDir, self.Table.GetDbName(), self.Table.GetTbName(), ext)
 		util.WriteFile(deleteFile, sqlText.String())
 	}
 
 	if len(self.SourceMore) > 0 {
 		sqlText.Reset()
-		for idText, _ := range self.SourceMore {
-			_sql, err := self.Table.GetRepairSQL(idText, 1)
+		for id, _ := range self.SourceMore {
+			_sql, err := self.Table.GetRepairSQL(id, 1)
 			if err != nil {
-				slog.Errorf("[%s.%s] 导出insert.sql文件报错: %s", self.Table.GetDbName(), self.Table.GetTbName(), err)
+				slog.Errorf("[%s.%s] 导出insert.%s文件报错: %s", self.Table.GetDbName(), self.Table.GetTbName(), ext, err)
 			} else {
 				sqlText.WriteString(_sql)
 			}
 		}
-		insertFile := fmt.Sprintf("%s/%s/%s.insert.sql", self.Options.BaseDir, self.Table.GetDbName(), self.Table.GetTbName())
+		insertFile := fmt.Sprintf("%s/%s/%s.insert.%s", self.Options.BaseDir, self.Table.GetDbName(), self.Table.GetTbName(), ext)
 		util.WriteFile(insertFile, sqlText.String())
 	}
 
 	if len(self.Diff) > 0 {
 		sqlText.Reset()
-		for _, idText := range self.Diff {
-			_sql, err := self.Table.GetRepairSQL(idText, 0)
+		for _, id := range self.Diff {
+			_sql, err := self.Table.GetRepairSQL(id, 0)
 			if err != nil {
-				slog.Errorf("[%s.%s] 导出update.sql文件报错: %s", self.Table.GetDbName(), self.Table.GetTbName(), err)
+				slog.Errorf("[%s.%s] 导出update.%s文件报错: %s", self.Table.GetDbName(), self.Table.GetTbName(), ext, err)
 			} else {
 				sqlText.WriteString(_sql)
 			}
 		}
-		updateFile := fmt.Sprintf("%s/%s/%s.update.sql", self.Options.BaseDir, self.Table.GetDbName(), self.Table.GetTbName())
+		updateFile := fmt.Sprintf("%s/%s/%s.update.%s", self.Options.BaseDir, self.Table.GetDbName(), self.Table.GetTbName(), ext)
 		util.WriteFile(updateFile, sqlText.String())
 	}
 
@@ -437,13 +462,21 @@ func (self *Checker) SaveResult() {
 		status = "未知"
 	}
 
-	text := fmt.Sprintf("%s,%s,%s,%d,%d,%d,%d,%d,%d,%d,%d,%s\n", self.Result.DbName, self.Result.TbName, status, self.Result.ExecuteSeconds,
-		self.Result.SourceRows, self.Result.TargetRows, self.Result.SameRows, self.Result.DiffRows, self.Result.SourceMoreRows, self.Result.TargetMoreRows, self.Result.RecheckPassRows, self.Result.Message)
-	util.WriteFileTail(csvFileName, text)
+	//Message中可能有逗号、引号和换行，按csv的规则转义
+	var text strings.Builder
+	w := csv.NewWriter(&text)
+	w.Write([]string{self.Result.DbName, self.Result.TbName, status, strconv.Itoa(self.Result.ExecuteSeconds),
+		strconv.Itoa(self.Result.SourceRows), strconv.Itoa(self.Result.TargetRows), strconv.Itoa(self.Result.SameRows), strconv.Itoa(self.Result.DiffRows),
+		strconv.Itoa(self.Result.SourceMoreRows), strconv.Itoa(self.Result.TargetMoreRows), strconv.Itoa(self.Result.RecheckPassRows), self.Result.Message})
+	w.Flush()
+	util.WriteFileTail(csvFileName, text.String())
 
 	if self.Options.Mode == "count" {
 		slog.Info(fmt.Sprintf("[%s.%s] 核对结果 [Status:%d SourceRows:%d TargetRows:%d]",
 			self.Result.DbName, self.Result.TbName, self.Result.Status, self.Result.SourceRows, self.Result.TargetRows))
+	} else if self.Options.Mode == "schema" {
+		slog.Info(fmt.Sprintf("[%s.%s] 核对结果 [Mode:schema Status:%d Diffs:%d]",
+			self.Result.DbName, self.Result.TbName, self.Result.Status, len(self.Result.SchemaDiffs)))
 	} else {
 		slog.Info(fmt.Sprintf("[%s.%s] 核对结果 [Status:%d SourceRows:%d TargetRows:%d SameRows:%d DiffRows:%d SourceMoreRows:%d TargetMoreRows:%d RecheckPassRows:%d]",
 			self.Result.DbName, self.Result.TbName, self.Result.Status, self.Result.SourceRows, self.Result.TargetRows, self.Result.SameRows, self.Result.DiffRows, self.Result.SourceMoreRows, self.Result.TargetMoreRows, self.Result.RecheckPassRows))
@@ -456,7 +489,7 @@ func (self *Checker) SaveResult() {
 			slog.Errorf("写入文件%s报错: %s", diffFileName, err)
 		} else {
 			for _, id := range self.Diff {
-				diffFile.WriteString(id + "\n")
+				diffFile.WriteString(id.String() + "\n")
 			}
 			diffFile.Close()
 		}
@@ -469,7 +502,7 @@ func (self *Checker) SaveResult() {
 			slog.Errorf("写入文件%s报错: %s", tLossFileName, err)
 		} else {
 			for id, _ := range self.SourceMore {
-				tLossFile.WriteString(id + "\n")
+				tLossFile.WriteString(id.String() + "\n")
 			}
 			tLossFile.Close()
 		}
@@ -482,9 +515,14 @@ func (self *Checker) SaveResult() {
 			slog.Errorf("写入文件%s报错: %s", tMoreFileName, err)
 		} else {
 			for id, _ := range self.TargetMore {
-				tMoreFile.WriteString(id + "\n")
+				tMoreFile.WriteString(id.String() + "\n")
 			}
 			tMoreFile.Close()
 		}
 	}
+
+	//结果文件写完后再写jsonl，记录文件路径
+	if err := saveJsonLine(self.Options, self.Result); err != nil {
+		slog.Error(err)
+	}
 }
@@ -0,0 +1,164 @@
+package check
+
+import (
+	"bufio"
+	"checkData/model"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gookit/slog"
+	"os"
+	"sync"
+)
+
+/*
+Checkpoint 断点文件，记录已经核对完成的表和数据块，使用--resume重新执行时跳过这些表和数据块
+文件为 <BaseDir>/<db>.checkpoint，每行一个json记录，只追加写入，进程被杀掉时最后一行可能不完整，加载时会忽略
+数据块按边界识别，重新拆分后边界不同的数据块会重新核对
+核对失败(Status=-1)的表和数据块不记录，重新执行时会再次核对
+*/
+type Checkpoint struct {
+	FileName string
+	file     *os.File
+	mu       sync.Mutex
+	tables   map[string]*model.Result
+	chunks   map[string]map[model.Key]*chunkRecord
+}
+
+type checkpointRecord struct {
+	Table  string        `json:"table"`
+	Result *model.Result `json:"result,omitempty"`
+	Chunk  *chunkRecord  `json:"chunk,omitempty"`
+}
+
+type chunkRecord struct {
+	Lower      *string              `json:"lower"`
+	Upper      *string              `json:"upper"`
+	SourceRows int                  `json:"source_rows"`
+	TargetRows int                  `json:"target_rows"`
+	SameRows   int                  `json:"same_rows"`
+	Diff       []model.Key          `json:"diff,omitempty"`
+	SourceMore map[model.Key]string `json:"source_more,omitempty"`
+	TargetMore map[model.Key]string `json:"target_more,omitempty"`
+}
+
+func chunkId(lower, upper *string) model.Key {
+	var values []any
+	for _, v := range []*string{lower, upper} {
+		if v == nil {
+			values = append(values, nil)
+		} else {
+			values = append(values, *v)
+		}
+	}
+	return model.NewKey(values)
+}
+
+func OpenCheckpoint(fileName string, resume bool) (*Checkpoint, error) {
+	//resume为false时清空断点文件，否则加载已完成的记录并追加写入
+	cp := &Checkpoint{
+		FileName: fileName,
+		tables:   make(map[string]*model.Result),
+		chunks:   make(map[string]map[model.Key]*chunkRecord),
+	}
+
+	flag := os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	if resume {
+		err := cp.load()
+		if err != nil {
+			return nil, fmt.Errorf("OpenCheckpoint -> %w", err)
+		}
+	} else {
+		flag |= os.O_TRUNC
+	}
+
+	f, err := os.OpenFile(fileName, flag, 0664)
+	if err != nil {
+		return nil, fmt.Errorf("OpenCheckpoint -> %w", err)
+	}
+	cp.file = f
+	return cp, nil
+}
+
+func (self *Checkpoint) load() error {
+	f, err := os.Open(self.FileName)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 1024*1024), 1024*1024*1024)
+	for scanner.Scan() {
+		var rec checkpointRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			slog.Warnf("忽略不完整的断点记录: %s", self.FileName)
+			continue
+		}
+		if rec.Result != nil {
+			self.tables[rec.Table] = rec.Result
+		} else if rec.Chunk != nil {
+			if self.chunks[rec.Table] == nil {
+				self.chunks[rec.Table] = make(map[model.Key]*chunkRecord)
+			}
+			self.chunks[rec.Table][chunkId(rec.Chunk.Lower, rec.Chunk.Upper)] = rec.Chunk
+		}
+	}
+	return scanner.Err()
+}
+
+func (self *Checkpoint) write(rec *checkpointRecord) {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		slog.Errorf("写入断点文件报错: %s", err)
+		return
+	}
+	data = append(data, '\n')
+
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if _, err = self.file.Write(data); err != nil {
+		slog.Errorf("写入断点文件报错: %s", err)
+	}
+}
+
+func (self *Checkpoint) GetTable(tbName string) *model.Result {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return self.tables[tbName]
+}
+
+func (self *Checkpoint) SaveTable(result *model.Result) {
+	if result.Status == -1 {
+		return
+	}
+	self.write(&checkpointRecord{Table: result.TbName, Result: result})
+}
+
+func (self *Checkpoint) GetChunk(tbName string, chunk *model.Chunk) *chunkRecord {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return self.chunks[tbName][chunkId(chunk.Lower, chunk.Upper)]
+}
+
+func (self *Checkpoint) SaveChunk(tbName string, chunk *model.Chunk, part *Checker) {
+	if part.Result.Status == -1 {
+		return
+	}
+	self.write(&checkpointRecord{Table: tbName, Chunk: &chunkRecord{
+		Lower:      chunk.Lower,
+		Upper:      chunk.Upper,
+		SourceRows: part.Result.SourceRows,
+		TargetRows: part.Result.TargetRows,
+		SameRows:   part.Result.SameRows,
+		Diff:       part.Diff,
+		SourceMore: part.SourceMore,
+		TargetMore: part.TargetMore,
+	}})
+}
+
+func (self *Checkpoint) Close() {
+	self.file.Close()
+}
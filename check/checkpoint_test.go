@@ -0,0 +1,58 @@
+package check
+
+import (
+	"checkData/model"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckpoint(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "db1.checkpoint")
+	cp, err := OpenCheckpoint(fileName, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	upper := "100"
+	chunk := &model.Chunk{Index: 0, Upper: &upper}
+	part := &Checker{
+		Result:     &model.Result{SourceRows: 3, TargetRows: 2, SameRows: 1},
+		Diff:       []model.Key{"1"},
+		SourceMore: map[model.Key]string{"2": "abc"},
+		TargetMore: map[model.Key]string{},
+	}
+	cp.SaveChunk("t1", chunk, part)
+	cp.SaveTable(&model.Result{DbName: "db1", TbName: "t2", Status: 1, SourceRows: 10})
+	cp.SaveTable(&model.Result{DbName: "db1", TbName: "t3", Status: -1})
+	cp.Close()
+
+	//模拟进程被杀掉时写了一半的记录
+	f, err := os.OpenFile(fileName, os.O_WRONLY|os.O_APPEND, 0664)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"table":"t4","res`)
+	f.Close()
+
+	cp, err = OpenCheckpoint(fileName, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cp.Close()
+
+	if r := cp.GetTable("t2"); r == nil || r.SourceRows != 10 {
+		t.Fatalf("GetTable(t2) = %v", r)
+	}
+	if r := cp.GetTable("t3"); r != nil {
+		t.Fatal("failed table should not be recorded")
+	}
+	rec := cp.GetChunk("t1", &model.Chunk{Index: 5, Upper: &upper})
+	if rec == nil || rec.SourceRows != 3 || len(rec.Diff) != 1 || rec.SourceMore["2"] != "abc" {
+		t.Fatalf("GetChunk(t1) = %+v", rec)
+	}
+	lower := "100"
+	if rec := cp.GetChunk("t1", &model.Chunk{Lower: &lower}); rec != nil {
+		t.Fatal("chunk with other bounds should not match")
+	}
+}
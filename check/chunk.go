@@ -0,0 +1,160 @@
+package check
+
+import (
+	"checkData/model"
+	"checkData/util"
+	"fmt"
+	"github.com/gookit/slog"
+	"sync"
+)
+
+func (self *Checker) CheckChunks() {
+	// 把表拆分成多个数据块，每个数据块使用单独的Checker并行核对，最后汇总到当前Checker
+	tb, ok := self.Table.(model.ChunkTable)
+	if !ok {
+		slog.Warnf("[%s.%s] 不支持拆分数据块(chunk-size不生效)，核对整个表", self.Table.GetDbName(), self.Table.GetTbName())
+		self.CheckDetail()
+		return
+	}
+
+	chunks, err := tb.GetChunks(self.Options.ChunkSize)
+	if err != nil {
+		self.Result.Status = -1
+		self.Result.Message = err.Error()
+		slog.Errorf("[%s.%s] 拆分数据块报错：%s", self.Table.GetDbName(), self.Table.GetTbName(), err)
+		return
+	}
+
+	defer util.TimeCost()(fmt.Sprintf("[%s.%s] 表明细数据核对完成", self.Table.GetDbName(), self.Table.GetTbName()))
+	slog.Infof("[%s.%s] 开始核对表明细数据 [数据块:%d 并行:%d]", self.Table.GetDbName(), self.Table.GetTbName(), len(chunks), self.Options.ChunkParallel)
+
+	var wg sync.WaitGroup
+	mu := &sync.Mutex{}
+	sem := make(chan struct{}, self.Options.ChunkParallel)
+	for _, chunk := range chunks {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(chunk *model.Chunk) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			//每个数据块单独汇总，完成后写入断点文件
+			part := self.newPart()
+			if rec := self.getCheckpointChunk(chunk); rec != nil {
+				slog.Infof("[%s.%s] 数据块%s已核对，跳过", self.Table.GetDbName(), self.Table.GetTbName(), chunk)
+				part.restore(rec)
+			} else {
+				part.checkChunk(tb, chunk, &sync.Mutex{})
+				if self.Checkpoint != nil {
+					self.Checkpoint.SaveChunk(self.Table.GetTbName(), chunk, part)
+				}
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			self.merge(part)
+		}(chunk)
+	}
+	wg.Wait()
+}
+
+func (self *Checker) checkChunk(tb model.ChunkTable, chunk *model.Chunk, mu *sync.Mutex) {
+	// 开启--chunk-checksum时先对比两端的聚合校验和，一致则跳过，不一致且行数较多时二分后递归对比，否则逐行核对
+	if sumTb, ok := tb.(model.ChunkSumTable); ok && self.Options.ChunkChecksum {
+		source, target, err := sumTb.GetChunkSum(chunk)
+		if err != nil {
+			slog.Errorf("[%s.%s] 数据块%s计算校验和报错：%s", self.Table.GetDbName(), self.Table.GetTbName(), chunk, err)
+			mu.Lock()
+			defer mu.Unlock()
+			self.Result.Status = -1
+			self.Result.Message = err.Error()
+			return
+		}
+
+		if source == target {
+			slog.Debugf("[%s.%s] 数据块%s校验和一致 [Rows:%d]", self.Table.GetDbName(), self.Table.GetTbName(), chunk, source.Rows)
+			mu.Lock()
+			defer mu.Unlock()
+			self.Result.SourceRows += source.Rows
+			self.Result.TargetRows += target.Rows
+			self.Result.SameRows += source.Rows
+			return
+		}
+
+		if max(source.Rows, target.Rows) > self.Options.ChunkMinRows {
+			subs, err := sumTb.SplitChunk(chunk, source.Rows)
+			if err != nil {
+				slog.Errorf("[%s.%s] 数据块%s拆分报错：%s", self.Table.GetDbName(), self.Table.GetTbName(), chunk, err)
+			} else if len(subs) > 0 {
+				slog.Infof("[%s.%s] 数据块%s校验和不一致，拆分后继续对比 [SourceRows:%d TargetRows:%d]", self.Table.GetDbName(), self.Table.GetTbName(), chunk, source.Rows, target.Rows)
+				for _, sub := range subs {
+					self.checkChunk(tb, sub, mu)
+				}
+				return
+			}
+		}
+	}
+
+	sub := NewChecker(tb.NewChunkTable(chunk), self.Options)
+	if sub.Result.Status != -1 {
+		sub.CheckDetail()
+	}
+	slog.Infof("[%s.%s] 数据块%s核对完成 [SourceRows:%d TargetRows:%d SameRows:%d]", self.Table.GetDbName(), self.Table.GetTbName(), chunk,
+		sub.Result.SourceRows, sub.Result.TargetRows, sub.Result.SameRows)
+
+	mu.Lock()
+	defer mu.Unlock()
+	self.merge(sub)
+}
+
+func (self *Checker) newPart() *Checker {
+	//汇总单个数据块结果的Checker
+	return &Checker{
+		Table:      self.Table,
+		Capacity:   self.Capacity,
+		SourceMore: make(map[model.Key]string),
+		TargetMore: make(map[model.Key]string),
+		Result:     &model.Result{DbName: self.Result.DbName, TbName: self.Result.TbName},
+		Options:    self.Options,
+	}
+}
+
+func (self *Checker) getCheckpointChunk(chunk *model.Chunk) *chunkRecord {
+	if self.Checkpoint == nil {
+		return nil
+	}
+	return self.Checkpoint.GetChunk(self.Table.GetTbName(), chunk)
+}
+
+func (self *Checker) restore(rec *chunkRecord) {
+	//从断点记录恢复数据块的核对结果
+	self.Result.SourceRows = rec.SourceRows
+	self.Result.TargetRows = rec.TargetRows
+	self.Result.SameRows = rec.SameRows
+	self.Diff = append(self.Diff, rec.Diff...)
+	for k, v := range rec.SourceMore {
+		self.SourceMore[k] = v
+	}
+	for k, v := range rec.TargetMore {
+		self.TargetMore[k] = v
+	}
+}
+
+func (self *Checker) merge(sub *Checker) {
+	//汇总数据块的核对结果
+	self.Result.SourceRows += sub.Result.SourceRows
+	self.Result.TargetRows += sub.Result.TargetRows
+	self.Result.SameRows += sub.Result.SameRows
+	if sub.Result.Status == -1 {
+		self.Result.Status = -1
+		self.Result.Message = sub.Result.Message
+	}
+
+	self.Diff = append(self.Diff, sub.Diff...)
+	for k, v := range sub.SourceMore {
+		self.SourceMore[k] = v
+	}
+	for k, v := range sub.TargetMore {
+		self.TargetMore[k] = v
+	}
+}
@@ -0,0 +1,159 @@
+package check
+
+import (
+	"checkData/model"
+	"fmt"
+	"github.com/gookit/slog"
+	"html/template"
+	"os"
+	"sort"
+	"time"
+)
+
+func (self *Checker) collectSamples() {
+	//按主键查询两端的数据，依次取不一致、目标端缺失、目标端多出的数据，每个表最多HtmlSampleRows行
+	tb, ok := self.Table.(model.RowTable)
+	if !ok || self.Options.HtmlSampleRows <= 0 {
+		return
+	}
+
+	var ids []model.Key
+	var kinds []string
+	add := func(list []model.Key, kind string) {
+		for _, id := range list {
+			if len(ids) >= self.Options.HtmlSampleRows {
+				return
+			}
+			ids = append(ids, id)
+			kinds = append(kinds, kind)
+		}
+	}
+	add(self.Diff, "diff")
+	add(sortedKeys(self.SourceMore), "tlost")
+	add(sortedKeys(self.TargetMore), "tmore")
+
+	for i, id := range ids {
+		columns, source, target, err := tb.GetRows(id)
+		if err != nil {
+			slog.Errorf("[%s.%s] 查询html报告的样本数据报错: %s", self.Table.GetDbName(), self.Table.GetTbName(), err)
+			self.Result.Samples = append(self.Result.Samples, model.RowSample{Id: id, Kind: kinds[i], Error: err.Error()})
+			continue
+		}
+		self.Result.Samples = append(self.Result.Samples, model.NewRowSample(id, kinds[i], columns, source, target))
+	}
+}
+
+func sortedKeys(m map[model.Key]string) []model.Key {
+	list := make([]model.Key, 0, len(m))
+	for k := range m {
+		list = append(list, k)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i] < list[j] })
+	return list
+}
+
+var htmlFuncs = template.FuncMap{
+	"status": func(status int) string {
+		switch status {
+		case 0:
+			return "不一致"
+		case 1:
+			return "一致"
+		default:
+			return "未知"
+		}
+	},
+	"kind": func(kind string) string {
+		switch kind {
+		case "diff":
+			return "数据不一致"
+		case "tlost":
+			return "目标端缺失"
+		case "tmore":
+			return "目标端多出"
+		}
+		return kind
+	},
+	"value": func(row map[string]string, column string) string {
+		if row == nil {
+			return "(不存在)"
+		}
+		if v, ok := row[column]; ok {
+			return v
+		}
+		return "(无此列)"
+	},
+	"statusOrder": func() []int {
+		return []int{0, -1, 1}
+	},
+	"time": func(t time.Time) string {
+		return t.Format("2006-01-02 15:04:05")
+	},
+}
+
+// 报告不依赖外部的css和js，可以直接作为附件发送
+var htmlTemplate = template.Must(template.New("report").Funcs(htmlFuncs).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>checkData 核对报告</title>
+<style>
+body { font-family: Menlo, Consolas, "Microsoft YaHei", monospace; font-size: 13px; margin: 20px; color: #222; }
+table { border-collapse: collapse; margin: 8px 0 16px 0; }
+th, td { border: 1px solid #ccc; padding: 3px 8px; text-align: left; vertical-align: top; white-space: pre-wrap; }
+th { background: #f0f0f0; }
+.s0 { color: #c00; } .s1 { color: #080; } .s-1 { color: #c60; }
+tr.diff td { background: #fdd; }
+.missing { color: #999; }
+h3 { margin-top: 24px; }
+</style>
+</head>
+<body>
+<h1>checkData 核对报告</h1>
+{{range $r := .}}
+<h2>{{.SourceDb}} : {{.TargetDb}}</h2>
+<p>版本: {{.Version}} &nbsp; 开始: {{time .StartTime}} &nbsp; 结束: {{time .EndTime}} &nbsp; 模式: {{.Options.Mode}}</p>
+<p>数据不一致的表: {{len .DiffTables}} &nbsp; 核对失败的表: {{len .FailedTables}} &nbsp; 数据一致的表: {{len .SameTables}}</p>
+{{with .Tables}}{{if .SourceMore}}<p>TARGET端缺失的表: {{range .SourceMore}}{{.}} {{end}}</p>{{end}}{{if .TargetMore}}<p>SOURCE端缺失的表: {{range .TargetMore}}{{.}} {{end}}</p>{{end}}{{end}}
+<table>
+<tr><th>表名</th><th>结果</th><th>执行时间(秒)</th><th>SourceRows</th><th>TargetRows</th><th>SameRows</th><th>DiffRows</th><th>SourceMoreRows</th><th>TargetMoreRows</th><th>RecheckPassRows</th><th>Message</th></tr>
+{{range $status := statusOrder}}{{range $r.Results}}{{if eq .Status $status}}
+<tr><td>{{if .Samples}}<a href="#{{.DbName}}.{{.TbName}}">{{.TbName}}</a>{{else}}{{.TbName}}{{end}}</td><td class="s{{.Status}}">{{status .Status}}</td><td>{{.ExecuteSeconds}}</td><td>{{.SourceRows}}</td><td>{{.TargetRows}}</td><td>{{.SameRows}}</td><td>{{.DiffRows}}</td><td>{{.SourceMoreRows}}</td><td>{{.TargetMoreRows}}</td><td>{{.RecheckPassRows}}</td><td>{{.Message}}</td></tr>
+{{end}}{{end}}{{end}}
+</table>
+{{range .Results}}{{if .DiffPatterns}}
+<h3>{{.TbName}} 不一致原因分析</h3>
+<ul>{{range .DiffPatterns}}<li>{{.}}</li>{{end}}</ul>
+{{end}}{{end}}
+{{range .Results}}{{if .SchemaDiffs}}
+<h3>{{.TbName}} 表结构差异</h3>
+<ul>{{range .SchemaDiffs}}<li>{{.}}</li>{{end}}</ul>
+{{end}}{{end}}
+{{range .Results}}{{if .Samples}}
+<h3 id="{{.DbName}}.{{.TbName}}">{{.TbName}} 不一致数据的样本</h3>
+{{range .Samples}}
+<p>{{kind .Kind}} &nbsp; 主键: {{.Id}}</p>
+{{if .Error}}<p class="s-1">{{.Error}}</p>{{else}}
+<table>
+<tr><th>列名</th><th>Source</th><th>Target</th></tr>
+{{$s := .}}{{range .Columns}}<tr{{if $s.IsDiff .}} class="diff"{{end}}><td>{{.}}</td><td{{if not $s.Source}} class="missing"{{end}}>{{value $s.Source .}}</td><td{{if not $s.Target}} class="missing"{{end}}>{{value $s.Target .}}</td></tr>
+{{end}}
+</table>
+{{end}}{{end}}{{end}}{{end}}
+{{end}}
+</body>
+</html>
+`))
+
+func saveHtmlReport(fileName string, reports []*model.Report) error {
+	//一次运行的所有数据库的核对结果，表按 不一致、核对失败、一致 的顺序排列
+	f, err := os.OpenFile(fileName, os.O_WRONLY|os.O_TRUNC|os.O_CREATE, 0664)
+	if err != nil {
+		return fmt.Errorf("saveHtmlReport -> %w", err)
+	}
+	defer f.Close()
+	if err = htmlTemplate.Execute(f, reports); err != nil {
+		return fmt.Errorf("saveHtmlReport -> %w", err)
+	}
+	return nil
+}
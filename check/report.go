@@ -0,0 +1,138 @@
+package check
+
+import (
+	"checkData/model"
+	"checkData/util"
+	"encoding/json"
+	"fmt"
+	"github.com/gookit/slog"
+	"strings"
+	"time"
+)
+
+func newTableReport(opt *model.Options, result *model.Result) *model.TableReport {
+	//只列出本次核对有数据的结果文件，旧的文件可能还在目录中
+	files := []struct {
+		kind     string
+		rows     int
+		suffixes []string //修复文件的扩展名为sql或js(mongo)
+	}{
+		{"diff", result.DiffRows, []string{"diff"}},
+		{"diff_detail", result.DiffRows, []string{"diff.jsonl"}},
+		{"tlost", result.SourceMoreRows, []string{"tlost"}},
+		{"tmore", result.TargetMoreRows, []string{"tmore"}},
+		{"update", result.DiffRows, []string{"update.sql", "update.js"}},
+		{"insert", result.SourceMoreRows, []string{"insert.sql", "insert.js"}},
+		{"delete", result.TargetMoreRows, []string{"delete.sql", "delete.js"}},
+		{"alter", len(result.SchemaDiffs), []string{"alter.sql", "alter.js"}},
+	}
+	report := &model.TableReport{Result: result}
+	for _, f := range files {
+		if f.rows == 0 {
+			continue
+		}
+		for _, suffix := range f.suffixes {
+			fileName := fmt.Sprintf("%s/%s/%s.%s", opt.BaseDir, result.DbName, result.TbName, suffix)
+			if util.FileExists(fileName) {
+				if report.Files == nil {
+					report.Files = make(map[string]string)
+				}
+				report.Files[f.kind] = fileName
+				break
+			}
+		}
+	}
+	return report
+}
+
+func (self *Checker) SaveDiffDetail() {
+	//复核后仍然不一致的行，每行一个json记录哪些列不一致和两端的值，保存在 $table.diff.jsonl
+	//优先使用最后一次复核时查询到的数据，没有时再按主键查询两端的数据
+	tb, ok := self.Table.(model.RowTable)
+	if !ok || len(self.Diff) == 0 {
+		return
+	}
+	rtb, _ := self.Table.(model.RecheckRowTable)
+
+	var buf strings.Builder
+	details := make([]model.DiffDetail, 0, len(self.Diff))
+	for _, id := range self.Diff {
+		var sample model.RowSample
+		var columns []string
+		var source, target map[string]string
+		var err error
+		if ok = rtb != nil; ok {
+			columns, source, target, ok = rtb.GetRecheckRows(id)
+		}
+		if !ok {
+			columns, source, target, err = tb.GetRows(id)
+		}
+		if err != nil {
+			sample = model.RowSample{Id: id, Kind: "diff", Error: err.Error()}
+		} else {
+			sample = model.NewRowSample(id, "diff", columns, source, target)
+		}
+		detail := model.NewDiffDetail(sample)
+		details = append(details, detail)
+		data, err := json.Marshal(detail)
+		if err != nil {
+			slog.Errorf("[%s.%s] 导出diff.jsonl文件报错: %s", self.Table.GetDbName(), self.Table.GetTbName(), err)
+			return
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+	fileName := fmt.Sprintf("%s/%s/%s.diff.jsonl", self.Options.BaseDir, self.Table.GetDbName(), self.Table.GetTbName())
+	util.WriteFile(fileName, buf.String())
+
+	//按列统计不一致的原因，帮助判断是修复数据还是需要规范化
+	self.Result.DiffPatterns = model.AnalyzeDiffs(details)
+	for _, p := range self.Result.DiffPatterns {
+		slog.Infof("[%s.%s] 不一致原因 %s", self.Table.GetDbName(), self.Table.GetTbName(), p)
+	}
+}
+
+func saveJsonLine(opt *model.Options, result *model.Result) error {
+	//每个表核对完成后追加一行到 <BaseDir>/<db>.jsonl
+	data, err := json.Marshal(newTableReport(opt, result))
+	if err != nil {
+		return fmt.Errorf("saveJsonLine -> %w", err)
+	}
+	util.WriteFileTail(fmt.Sprintf("%s/%s.jsonl", opt.BaseDir, result.DbName), string(data)+"\n")
+	return nil
+}
+
+func newReport(opt *model.Options, dbg [2]string, start time.Time, tables *model.TableInfo, results []*model.Result) *model.Report {
+	//汇总一个数据库的核对结果，用于json和html报告
+	report := &model.Report{
+		Version:   model.Version,
+		StartTime: start,
+		EndTime:   time.Now(),
+		SourceDb:  dbg[0],
+		TargetDb:  dbg[1],
+		Options:   opt.Masked(),
+		Tables:    tables,
+	}
+	for _, v := range results {
+		switch v.Status {
+		case 1:
+			report.SameTables = append(report.SameTables, v.TbName)
+		case 0:
+			report.DiffTables = append(report.DiffTables, v.TbName)
+		default:
+			report.FailedTables = append(report.FailedTables, v.TbName)
+		}
+		report.Results = append(report.Results, newTableReport(opt, v))
+	}
+	return report
+}
+
+func saveJsonReport(fileName string, report *model.Report) error {
+	//写入 <BaseDir>/<db>.json
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("saveJsonReport -> %w", err)
+	}
+	util.WriteFile(fileName, string(data)+"\n")
+	return nil
+}
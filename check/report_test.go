@@ -0,0 +1,111 @@
+package check
+
+import (
+	"checkData/model"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJsonReport(t *testing.T) {
+	dir := t.TempDir()
+	opt := &model.Options{BaseDir: dir, Password: "secret", TargetDsn: "u:secret@tcp(10.0.0.1:3306)/"}
+	os.Mkdir(filepath.Join(dir, "db1"), 0775)
+	for _, name := range []string{"t1.tlost", "t1.insert.sql", "t1.diff"} {
+		os.WriteFile(filepath.Join(dir, "db1", name), []byte("1\n"), 0664)
+	}
+
+	//t1.diff是上次核对留下的文件，本次没有不一致的行
+	result := &model.Result{DbName: "db1", TbName: "t1", SourceRows: 2, SourceMoreRows: 1, RecheckPassRows: -1}
+	files := newTableReport(opt, result).Files
+	if len(files) != 2 || files["tlost"] != filepath.Join(dir, "db1", "t1.tlost") || files["insert"] != filepath.Join(dir, "db1", "t1.insert.sql") {
+		t.Fatalf("Files = %v", files)
+	}
+
+	fileName := filepath.Join(dir, "db1.json")
+	tables := &model.TableInfo{Source: []string{"t1"}, Target: []string{"t1"}, ToCheck: []string{"t1"}}
+	if err := saveJsonReport(fileName, newReport(opt, [2]string{"db1", "db1"}, time.Now(), tables, []*model.Result{result})); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "secret") {
+		t.Fatalf("password in report: %s", data)
+	}
+	var report model.Report
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Version != model.Version || len(report.Results) != 1 || report.Results[0].SourceMoreRows != 1 || len(report.DiffTables) != 1 || len(report.FailedTables) != 0 {
+		t.Fatalf("report = %+v", report)
+	}
+}
+
+func TestHtmlReport(t *testing.T) {
+	dir := t.TempDir()
+	opt := &model.Options{BaseDir: dir, Mode: "fast"}
+	source := map[string]string{"name": "<a>", "amount": "1.00"}
+	target := map[string]string{"name": "<a>", "amount": "1.10"}
+	result := &model.Result{DbName: "db1", TbName: "t1", DiffRows: 1, RecheckPassRows: 0,
+		Samples: []model.RowSample{model.NewRowSample("1", "diff", []string{"name", "amount"}, source, target)}}
+	report := newReport(opt, [2]string{"db1", "db1"}, time.Now(), &model.TableInfo{}, []*model.Result{result})
+
+	fileName := filepath.Join(dir, "report.html")
+	if err := saveHtmlReport(fileName, []*model.Report{report}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	html := string(data)
+	for _, s := range []string{`<tr class="diff"><td>amount</td><td>1.00</td><td>1.10</td></tr>`, "&lt;a&gt;", `href="#db1.t1"`} {
+		if !strings.Contains(html, s) {
+			t.Fatalf("%s not found in report:\n%s", s, html)
+		}
+	}
+}
+
+// recheckTable 复核时保存了两端数据的表，不应该再按主键查询
+type recheckTable struct {
+	model.Table
+	rows map[model.Key][2]map[string]string
+}
+
+func (self *recheckTable) GetDbName() string { return "db1" }
+func (self *recheckTable) GetTbName() string { return "t1" }
+
+func (self *recheckTable) GetRows(id model.Key) ([]string, map[string]string, map[string]string, error) {
+	return nil, nil, nil, fmt.Errorf("GetRows:unexpected query id:[%s]", id)
+}
+
+func (self *recheckTable) GetRecheckRows(id model.Key) ([]string, map[string]string, map[string]string, bool) {
+	rows, ok := self.rows[id]
+	return []string{"amount"}, rows[0], rows[1], ok
+}
+
+func TestSaveDiffDetail(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, "db1"), 0775)
+	tb := &recheckTable{rows: map[model.Key][2]map[string]string{"1": {{"amount": "1.00"}, {"amount": "1.0"}}}}
+	checker := &Checker{Table: tb, Diff: []model.Key{"1", "2"}, Result: &model.Result{}, Options: &model.Options{BaseDir: dir}}
+	checker.SaveDiffDetail()
+
+	details, err := model.LoadDiffDetails(filepath.Join(dir, "db1", "t1.diff.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(details) != 2 || len(details[0].Columns) != 1 || details[0].Columns[0].Pattern != "numeric_format" {
+		t.Fatalf("details = %+v", details)
+	}
+	//没有复核数据的行按主键查询
+	if !strings.Contains(details[1].Error, "unexpected query") {
+		t.Fatalf("details[1] = %+v", details[1])
+	}
+}
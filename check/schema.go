@@ -0,0 +1,56 @@
+package check
+
+import (
+	"checkData/model"
+	"checkData/util"
+	"fmt"
+	"github.com/gookit/slog"
+)
+
+func (self *Checker) CheckSchema() {
+	//核对两端的表结构，不核对数据
+	defer util.TimeCost()(fmt.Sprintf("[%s.%s] 表结构核对完成", self.Table.GetDbName(), self.Table.GetTbName()))
+	slog.Infof("[%s.%s] 开始核对表结构", self.Table.GetDbName(), self.Table.GetTbName())
+
+	tb, ok := self.Table.(model.SchemaTable)
+	if !ok {
+		self.Result.Status = -1
+		self.Result.Message = "不支持表结构核对"
+		slog.Errorf("[%s.%s] %s", self.Table.GetDbName(), self.Table.GetTbName(), self.Result.Message)
+		return
+	}
+
+	source, target, err := tb.GetTableDef()
+	if err != nil {
+		self.Result.Status = -1
+		self.Result.Message = err.Error()
+		slog.Error(err)
+		return
+	}
+
+	diffs := model.CompareTableDef(source, target, self.Options.ColumnMapList)
+	self.Result.SchemaDiffs = diffs
+	if len(diffs) == 0 {
+		self.Result.Status = 1
+		return
+	}
+
+	self.Result.Status = 0
+	self.Result.Message = fmt.Sprintf("表结构有%d处差异", len(diffs))
+	for _, d := range diffs {
+		slog.Infof("[%s.%s] %s", self.Table.GetDbName(), self.Table.GetTbName(), d)
+	}
+
+	if self.Options.AlterDDL {
+		self.SaveAlterDDL(tb)
+	}
+}
+
+func (self *Checker) SaveAlterDDL(tb model.SchemaTable) {
+	ext := "sql"
+	if t, ok := self.Table.(model.RepairScriptTable); ok {
+		ext = t.GetRepairFileExt()
+	}
+	alterFile := fmt.Sprintf("%s/%s/%s.alter.%s", self.Options.BaseDir, self.Table.GetDbName(), self.Table.GetTbName(), ext)
+	util.WriteFile(alterFile, tb.GetAlterDDL(self.Result.SchemaDiffs))
+}
@@ -0,0 +1,443 @@
+package cross
+
+import (
+	"checkData/model"
+	"checkData/util"
+	"database/sql"
+	"fmt"
+	"github.com/gookit/slog"
+	"strconv"
+	"strings"
+)
+
+func (self *Table) PreCheck() bool {
+	//预检查
+	defer func() {
+		slog.Infof("[%s.%s] Source SQLText: %s", self.DbName, self.TbName, self.SourceSQLText)
+		slog.Infof("[%s.%s] Target SQLText: %s", self.DbName, self.TbName, self.TargetSQLText)
+	}()
+
+	slog.Infof("[%s.%s] 执行预检查", self.DbName, self.TbName)
+
+	self.getEnclosedTbName()
+
+	//摘要算法
+	hashFunc, err := util.NewHashFunc(self.Hash)
+	if err != nil {
+		self.Result.Status = -1
+		self.Result.Message = err.Error()
+		slog.Error(err)
+		return false
+	}
+	self.hashFunc = hashFunc
+
+	if self.Mode == "count" {
+		self.SourceSQLText = fmt.Sprintf("select count(*) cnt from %s", self.SourceTbName)
+		self.TargetSQLText = fmt.Sprintf("select count(*) cnt from %s", self.TargetTbName)
+		if self.Where != "" {
+			self.SourceSQLText += " where " + self.Where
+		}
+		if where := self.getTargetWhere(); where != "" {
+			self.TargetSQLText += " where " + where
+		}
+		return true
+	}
+
+	if self.Mode != "slow" {
+		//不同数据库的校验和函数不一样，只能在本地计算
+		slog.Infof("[%s.%s] 跨数据库核对不支持%s模式，使用slow模式", self.DbName, self.TbName, self.Mode)
+		self.Mode = "slow"
+	}
+
+	//获取主键
+	err = self.getKeys()
+	if err != nil {
+		self.Result.Status = -1
+		self.Result.Message = err.Error()
+		slog.Error(err)
+		return false
+	}
+
+	//获取列名
+	err = self.getColumns()
+	if err != nil {
+		self.Result.Status = -1
+		self.Result.Message = err.Error()
+		slog.Error(err)
+		return false
+	}
+
+	//提除主键列和跳过的列
+	var _tmp, _targetTmp []string
+	var skipCols []string
+	for i, v := range self.Columns {
+		if util.InSlice(v, self.Keys) {
+			continue
+		} else if util.InSlice(v, self.SkipColumns) {
+			skipCols = append(skipCols, v)
+		} else {
+			_tmp = append(_tmp, v)
+			_targetTmp = append(_targetTmp, self.TargetColumns[i])
+		}
+	}
+	self.Columns = _tmp
+	self.TargetColumns = _targetTmp
+
+	if len(skipCols) > 0 {
+		slog.Infof("[%s.%s] 跳过不需要核对的列: %s", self.DbName, self.TbName, strings.Join(skipCols, ", "))
+	}
+
+	if len(self.Keys) == 0 {
+		//两端的唯一索引和值的格式不同，不支持按唯一索引或整行数据对比
+		self.Result.Status = -1
+		self.Result.Message = "Keys is empty, 跨数据库核对不支持没有主键的表，请使用--keys指定唯一的列"
+		slog.Errorf("[%s.%s] %s", self.DbName, self.TbName, self.Result.Message)
+		return false
+	}
+
+	if len(self.Columns) == 0 {
+		self.Result.Status = -1
+		self.Result.Message = "Columns is empty"
+		slog.Error(fmt.Errorf("Columns is empty"))
+		return false
+	}
+
+	err = self.getCheckSQL()
+	if err != nil {
+		self.Result.Status = -1
+		self.Result.Message = err.Error()
+		slog.Error(err)
+		return false
+	}
+
+	//复核和修复SQL需要按两端主键列的类型生成条件
+	err = self.getKeyKinds()
+	if err != nil {
+		self.Result.Status = -1
+		self.Result.Message = err.Error()
+		slog.Error(err)
+		return false
+	}
+	return true
+}
+
+func (self *Table) pullDataSum(side *Endpoint, sqlText string, rowCount *int, dataCh chan<- *model.Data, doneCh <-chan struct{}) error {
+	// 获取数据，把值转换成统一格式后，在本地计算摘要，两端按规范化后的主键对应
+
+	cur, err := side.Conn.Query(sqlText)
+	if err != nil {
+		return fmt.Errorf("pullDataSum:Query-> %w", err)
+	}
+	defer cur.Close()
+
+	kinds, err := util.GetValueKinds(cur)
+	if err != nil {
+		return err
+	}
+
+	values := make([]sql.RawBytes, len(kinds))
+	valuesP := make([]interface{}, len(kinds))
+	for i := range values {
+		valuesP[i] = &values[i]
+	}
+
+	var kb model.KeyBuilder
+	var buf2 []byte
+
+	for cur.Next() {
+
+		if err := cur.Scan(valuesP...); err != nil {
+			return err
+		}
+
+		kb.Reset()
+		buf2 = buf2[:0]
+
+		//拼接id
+		for i := 0; i < len(self.Keys); i++ {
+			if values[i] == nil {
+				kb.Append(nil, true)
+			} else {
+				kb.Append([]byte(util.NormalizeValue(values[i], kinds[i])), false)
+			}
+		}
+
+		// 拼接数据，每个值带长度前缀，NULL使用单独的标记
+		for i := len(self.Keys); i < len(values); i++ {
+			if values[i] == nil {
+				buf2 = util.AppendEncodedValue(buf2, nil, true)
+			} else {
+				buf2 = util.AppendEncodedValue(buf2, []byte(util.NormalizeValue(values[i], kinds[i])), false)
+			}
+		}
+
+		data := model.Data{Id: kb.Key(), Sum: self.hashFunc(buf2)}
+		select {
+		case dataCh <- &data:
+			*rowCount++
+		case <-doneCh:
+			slog.Infof("收到停止信号，结束数据下载[%s.%s]", side.Db, self.TbName)
+			return nil
+		}
+
+	}
+	return cur.Err()
+}
+
+func (self *Table) PullSourceDataSum(dataCh chan<- *model.Data, doneCh <-chan struct{}) {
+	defer util.TimeCost()(fmt.Sprintf("[%s.%s] Source端数据下载完成", self.DbName, self.TbName))
+	defer close(dataCh)
+
+	slog.Infof("[%s.%s] 开始下载Source端数据", self.DbGroup.SourceDb, self.TbName)
+	err := self.pullDataSum(self.DbGroup.Source, self.SourceSQLText, &self.Result.SourceRows, dataCh, doneCh)
+	if err != nil {
+		self.Result.Status = -1
+		self.Result.Message = err.Error()
+		slog.Error(self.Result.Message)
+		return
+	}
+}
+
+func (self *Table) PullTargetDataSum(dataCh chan<- *model.Data, doneCh <-chan struct{}) {
+	defer util.TimeCost()(fmt.Sprintf("[%s.%s] Target端数据下载完成", self.DbName, self.TbName))
+	defer close(dataCh)
+
+	slog.Infof("[%s.%s] 开始下载Target端数据", self.DbGroup.TargetDb, self.TbName)
+	err := self.pullDataSum(self.DbGroup.Target, self.TargetSQLText, &self.Result.TargetRows, dataCh, doneCh)
+	if err != nil {
+		self.Result.Status = -1
+		self.Result.Message = err.Error()
+		slog.Error(self.Result.Message)
+		return
+	}
+}
+
+func (self *Table) getTableCount(side *Endpoint, sqlText string) (int, error) {
+	rows, err := util.QueryReturnList(side.Conn, sqlText)
+	if err != nil {
+		return 0, err
+	}
+	cnt, err := strconv.Atoi(rows[0][0])
+	if err != nil {
+		return 0, fmt.Errorf("Atoi -> %w", err)
+	}
+	return cnt, nil
+}
+
+func (self *Table) GetSourceTableCount() {
+	// 返回表总行数
+	defer util.TimeCost()(fmt.Sprintf("[%s.%s] Source端总行数统计完成", self.DbGroup.SourceDb, self.TbName))
+
+	cnt, err := self.getTableCount(self.DbGroup.Source, self.SourceSQLText)
+	if err != nil {
+		self.Result.Status = -1
+		self.Result.Message = fmt.Errorf("GetSourceTableCount -> %w", err).Error()
+		slog.Error(err)
+		return
+	}
+	self.Result.SourceRows = cnt
+}
+
+func (self *Table) GetTargetTableCount() {
+	// 返回表总行数
+	defer util.TimeCost()(fmt.Sprintf("[%s.%s] Target端总行数统计完成", self.DbGroup.TargetDb, self.TbName))
+
+	cnt, err := self.getTableCount(self.DbGroup.Target, self.TargetSQLText)
+	if err != nil {
+		self.Result.Status = -1
+		self.Result.Message = fmt.Errorf("GetTargetTableCount -> %w", err).Error()
+		slog.Error(err)
+		return
+	}
+	self.Result.TargetRows = cnt
+}
+
+func (self *Table) getWhereClause(side *Endpoint, id model.Key) (string, error) {
+	//id是规范化后的主键值(二进制为十六进制、uuid为小写等)，按这一端主键列的类型生成字面量，只在一端出现的id也能匹配
+	keys, kinds := self.Keys, self.keyKinds[0]
+	if side == self.DbGroup.Target {
+		keys, kinds = self.TargetKeys, self.keyKinds[1]
+	}
+
+	values, err := id.Values()
+	if err != nil {
+		return "", fmt.Errorf("getWhereClause -> %w", err)
+	}
+	if len(values) != len(keys) || len(kinds) != len(keys) {
+		return "", fmt.Errorf("getWhereClause:主键列数不一致 id:[%s]", id)
+	}
+
+	//拼接where，NULL值使用is null
+	list := make([]string, len(keys))
+	for i, k := range keys {
+		if values[i] == nil {
+			list[i] = util.EncloseStr(k, side.Dialect.Quote()) + " IS NULL"
+		} else {
+			list[i] = util.EncloseStr(k, side.Dialect.Quote()) + "=" + keyLiteral(side.Dialect, values[i].(string), kinds[i])
+		}
+	}
+	return strings.Join(list, " AND "), nil
+}
+
+func (self *Table) queryRow(side *Endpoint, tbName string, id model.Key) ([]map[string]string, error) {
+	whereClause, err := self.getWhereClause(side, id)
+	if err != nil {
+		return nil, err
+	}
+	sql := fmt.Sprintf("select %s from %s where %s", self.selectList(side, self.Columns), tbName, whereClause)
+	return util.QueryReturnNormalizedDict(side.Conn, sql)
+}
+
+func (self *Table) GetRows(id model.Key) (columns []string, source map[string]string, target map[string]string, err error) {
+	//html报告中展示两端的数据，值已经按类型规范化
+	srow, err := self.queryRow(self.DbGroup.Source, self.SourceTbName, id)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("GetRows:Source端 -> %w", err)
+	}
+	trow, err := self.queryRow(self.DbGroup.Target, self.TargetTbName, id)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("GetRows:Target端 -> %w", err)
+	}
+	if len(srow) > 0 {
+		source = srow[0]
+	}
+	if len(trow) > 0 {
+		target = trow[0]
+	}
+	return self.Columns, source, target, nil
+}
+
+func (self *Table) recheckOne(id model.Key) bool {
+	//复核一行数据,相同返回true
+	srow, err := self.queryRow(self.DbGroup.Source, self.SourceTbName, id)
+	if err != nil {
+		slog.Errorf("[%s.%s] 复核不一致的数据，查询Source端报错：%s", self.DbName, self.TbName, err)
+		return false
+	}
+	trow, err := self.queryRow(self.DbGroup.Target, self.TargetTbName, id)
+	if err != nil {
+		slog.Errorf("[%s.%s] 复核不一致的数据，查询Target端报错：%s", self.DbName, self.TbName, err)
+		return false
+	}
+	self.saveRecheckRows(id, srow, trow)
+	if len(srow) == 0 && len(trow) == 0 {
+		slog.Infof("[%s.%s] 两端均无此数据,复核通过 id:[%s]", self.DbName, self.TbName, id)
+		return true
+	} else if len(srow) == 1 && len(srow) == len(trow) {
+		if res, str := util.MapIsEqual(srow[0], trow[0]); res {
+			slog.Infof("[%s.%s] 数据一致,复核通过 id:[%s]", self.DbName, self.TbName, id)
+			return true
+		} else {
+			slog.Infof("[%s.%s] 数据不一致,复核不通过 id:[%s] %s", self.DbName, self.TbName, id, str)
+		}
+	} else {
+		slog.Infof("[%s.%s] 两端数据行数不一致，复核不通过 id:[%s] rows:[%d] vs [%d]", self.DbName, self.TbName, id, len(srow), len(trow))
+	}
+	return false
+}
+
+func (self *Table) saveRecheckRows(id model.Key, srow []map[string]string, trow []map[string]string) {
+	//复核不通过时diff.jsonl直接使用这次查询的数据，不需要再次查询
+	var rows [2]map[string]string
+	if len(srow) > 0 {
+		rows[0] = srow[0]
+	}
+	if len(trow) > 0 {
+		rows[1] = trow[0]
+	}
+	self.recheckRows[id] = rows
+}
+
+func (self *Table) GetRecheckRows(id model.Key) (columns []string, source map[string]string, target map[string]string, ok bool) {
+	rows, ok := self.recheckRows[id]
+	if !ok {
+		return nil, nil, nil, false
+	}
+	return self.Columns, rows[0], rows[1], true
+}
+
+func (self *Table) Recheck(ids []model.Key) (passList []model.Key) {
+	//每次复核只保留这一次的数据
+	self.recheckRows = make(map[model.Key][2]map[string]string, len(ids))
+	for _, id := range ids {
+		if self.recheckOne(id) {
+			passList = append(passList, id)
+		}
+	}
+	return passList
+}
+
+func (self *Table) GetRepairSQL(id model.Key, mode int) (string, error) {
+	// 生成修复目标端数据的sql，使用目标端的语法和列名
+	// mode:修复模式, -1:delete, 0:update  1:insert
+	if !util.InSlice(mode, []int{-1, 0, 1}) {
+		return "", fmt.Errorf("GetRepairSQL:Invalid mode %d", mode)
+	}
+
+	source := self.DbGroup.Source
+	target := self.DbGroup.Target
+	tq := target.Dialect.Quote()
+
+	targetWhereClause, err := self.getWhereClause(target, id)
+	if err != nil {
+		return "", fmt.Errorf("GetRepairSQL -> %w", err)
+	}
+
+	var sqlText strings.Builder
+	switch mode {
+	case -1:
+		//生成delete SQL
+		sqlText.WriteString(fmt.Sprintf("DELETE FROM %s WHERE %s;\n", self.TargetTbName, targetWhereClause))
+	case 0:
+		//生成update SQL
+		sourceWhereClause, err := self.getWhereClause(source, id)
+		if err != nil {
+			return "", fmt.Errorf("GetRepairSQL -> %w", err)
+		}
+		sql := fmt.Sprintf("select %s from %s where %s", util.EncloseAndJoin(self.Columns, source.Dialect.Quote()), self.SourceTbName, sourceWhereClause)
+		rows, kinds, err := util.QueryReturnNormalizedList(source.Conn, sql)
+		if err != nil {
+			return "", err
+		}
+		if len(rows) == 0 {
+			return "", fmt.Errorf("GetRepairSQL:Source端数据不存在 id:[%s]", id)
+		}
+		row := self.encloseValues(rows[0], kinds, target.Dialect)
+		var setClause string
+		setClause, err = util.GenerateClause(self.TargetColumns, row, tq, ",")
+		if err != nil {
+			return "", fmt.Errorf("GetRepairSQL:GenerateClause -> %w", err)
+		}
+
+		sqlText.WriteString(fmt.Sprintf("UPDATE %s SET %s WHERE %s;\n", self.TargetTbName, setClause, targetWhereClause))
+
+	case 1:
+		//生成insert SQL
+		sourceWhereClause, err := self.getWhereClause(source, id)
+		if err != nil {
+			return "", fmt.Errorf("GetRepairSQL -> %w", err)
+		}
+		var columns, targetColumns []string
+		columns = append(columns, self.Keys...)
+		columns = append(columns, self.Columns...)
+		targetColumns = append(targetColumns, self.TargetKeys...)
+		targetColumns = append(targetColumns, self.TargetColumns...)
+
+		sql := fmt.Sprintf("select %s from %s where %s", util.EncloseAndJoin(columns, source.Dialect.Quote()), self.SourceTbName, sourceWhereClause)
+		rows, kinds, err := util.QueryReturnNormalizedList(source.Conn, sql)
+		if err != nil {
+			return "", err
+		}
+
+		for _, v := range rows {
+			row := self.encloseValues(v, kinds, target.Dialect)
+			sqlText.WriteString(fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s);\n", self.TargetTbName, util.EncloseAndJoin(targetColumns, tq), strings.Join(row, ", ")))
+		}
+	}
+
+	return sqlText.String(), nil
+}
+
+func (self *Table) GetResult() *model.Result {
+	return self.Result
+}
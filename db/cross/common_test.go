@@ -0,0 +1,55 @@
+package cross
+
+import (
+	"checkData/model"
+	"checkData/util"
+	"testing"
+)
+
+func TestGetWhereClause(t *testing.T) {
+	//源端mssql，目标端pgsql，主键为二进制、uuid和数字
+	source := &Endpoint{Dialect: &mssqlDialect{}}
+	target := &Endpoint{Dialect: &pgsqlDialect{}}
+	tb := Table{
+		Keys:       []string{"bin", "uid", "n", "flag"},
+		TargetKeys: []string{"BIN", "UID", "N", "FLAG"},
+		keyKinds: [2][]util.ValueKind{
+			{util.KindBinary, util.KindUuid, util.KindNumber, util.KindBool},
+			{util.KindBinary, util.KindUuid, util.KindNumber, util.KindBool},
+		},
+		DbGroup: &Database{Source: source, Target: target},
+	}
+
+	//只在目标端出现的id，在源端复核时也要使用源端的字面量
+	id := model.NewKey([]any{"0aff", "6f9619ff-8b86-d011-b42d-00c04fc964ff", "10", "1"})
+	cases := []struct {
+		side *Endpoint
+		want string
+	}{
+		{source, `"bin"=0x0aff AND "uid"='6f9619ff-8b86-d011-b42d-00c04fc964ff' AND "n"=10 AND "flag"=1`},
+		{target, `"BIN"=decode('0aff','hex') AND "UID"='6f9619ff-8b86-d011-b42d-00c04fc964ff' AND "N"=10 AND "FLAG"='1'`},
+	}
+	for _, c := range cases {
+		got, err := tb.getWhereClause(c.side, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != c.want {
+			t.Fatalf("getWhereClause = %s, want %s", got, c.want)
+		}
+	}
+
+	//NULL和需要转义的字符串
+	tb.keyKinds[0] = []util.ValueKind{util.KindString, util.KindString, util.KindNumber, util.KindString}
+	got, err := tb.getWhereClause(source, model.NewKey([]any{nil, "a'b", "x", "1"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `"bin" IS NULL AND "uid"='a''b' AND "n"='x' AND "flag"='1'`; got != want {
+		t.Fatalf("getWhereClause = %s, want %s", got, want)
+	}
+
+	if _, err := tb.getWhereClause(source, model.NewKey([]any{"1"})); err == nil {
+		t.Fatal("expected error for a key with fewer columns")
+	}
+}
@@ -0,0 +1,173 @@
+package cross
+
+import (
+	"checkData/model"
+	"checkData/util"
+	"database/sql"
+	"fmt"
+	"github.com/gookit/slog"
+)
+
+type Endpoint struct {
+	Db      string
+	Schema  string
+	Dialect Dialect
+	Conn    *sql.DB
+}
+
+type Database struct {
+	SourceDb   string
+	TargetDb   string
+	SourceHost string
+	SourcePort int
+	TargetHost string
+	TargetPort int
+	Source     *Endpoint
+	Target     *Endpoint
+	Option     *model.Options
+	Tables     *model.TableInfo
+}
+
+func (self *Database) getTables() (err error) {
+	// 获取表名，两端都使用不带schema的表名
+	tableS, err := util.QueryReturnList(self.Source.Conn, self.Source.Dialect.TablesSQL(self.Source.Schema))
+	if err != nil {
+		return fmt.Errorf("getTables -> %w", err)
+	}
+	for _, v := range tableS {
+		self.Tables.Source = append(self.Tables.Source, v[0])
+	}
+
+	tableT, err := util.QueryReturnList(self.Target.Conn, self.Target.Dialect.TablesSQL(self.Target.Schema))
+	if err != nil {
+		return fmt.Errorf("getTables -> %w", err)
+	}
+	for _, v := range tableT {
+		self.Tables.Target = append(self.Tables.Target, v[0])
+	}
+
+	return nil
+}
+
+func (self *Database) PreCheck() (err error) {
+	//获取两端都存在的表
+
+	if len(self.Tables.ToCheck) == 0 {
+		err = self.getTables()
+		if err != nil {
+			return fmt.Errorf("GetToCheck -> %w", err)
+		}
+
+		//目标库不存在的表，源端表名按映射规则转换成目标端表名后再匹配
+		var mapped []string
+		for _, t := range self.Tables.Source {
+			tt := self.Option.TargetTable(t)
+			mapped = append(mapped, tt)
+			if !util.InSlice(tt, self.Tables.Target) {
+				self.Tables.SourceMore = append(self.Tables.SourceMore, t)
+			} else {
+				self.Tables.ToCheck = append(self.Tables.ToCheck, t)
+			}
+		}
+
+		//源库不存在的表
+		for _, t := range self.Tables.Target {
+			if !util.InSlice(t, mapped) {
+				self.Tables.TargetMore = append(self.Tables.TargetMore, t)
+			}
+		}
+
+		//过滤不需要检查的表
+		if len(self.Tables.Skip) > 0 {
+			var tbs []string
+			for _, tb := range self.Tables.ToCheck {
+				if !util.InSlice(tb, self.Tables.Skip) {
+					tbs = append(tbs, tb)
+				}
+			}
+			self.Tables.ToCheck = tbs
+		}
+	}
+	return nil
+}
+
+func (self *Database) GetTableInfo() *model.TableInfo {
+	return self.Tables
+}
+
+func (self *Database) NewTable(tb string) model.Table {
+	opt := self.Option.ForTable(tb)
+	return &Table{
+		DbName:      self.TargetDb,
+		TbName:      tb,
+		TargetName:  self.Option.TargetTable(tb),
+		ColumnMap:   opt.ColumnMapList,
+		Mode:        self.Option.Mode,
+		SkipColumns: opt.SkipColList,
+		Keys:        opt.KeysList,
+		Where:       opt.SourceFilter(),
+		TargetWhere: opt.TargetFilter(),
+		Hash:        self.Option.Hash,
+		DbGroup:     self,
+		Result:      &model.Result{DbName: self.TargetDb, TbName: tb, RecheckPassRows: -1},
+	}
+}
+
+func (self *Database) Close() {
+	//关闭连接池
+	self.Source.Conn.Close()
+	self.Target.Conn.Close()
+	slog.Infof("[%s:%s] 关闭数据库连接池", self.SourceDb, self.TargetDb)
+}
+
+func newEndpoint(dbType, schema, dsn, host string, port int, user, password, dbName string) (*Endpoint, error) {
+	dialect, err := NewDialect(dbType)
+	if err != nil {
+		return nil, err
+	}
+	if schema == "" {
+		schema = dialect.DefaultSchema()
+	}
+	var conn *sql.DB
+	if dsn != "" {
+		conn, err = util.OpenDsn(dialect.Name(), dsn, dbName)
+	} else {
+		conn, err = dialect.Open(host, port, user, password, dbName)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Endpoint{Db: dbName, Schema: schema, Dialect: dialect, Conn: conn}, nil
+}
+
+func NewDatabase(opt *model.Options, dbg [2]string) (model.Database, error) {
+	slog.Infof("[%s:%s] 开启数据库连接池 [%s -> %s]", dbg[0], dbg[1], opt.SourceType, opt.TargetType)
+	source, err := newEndpoint(opt.SourceType, opt.SourceSchema, opt.SourceDsn, opt.SourceHost, opt.SourcePort, opt.User, opt.Password, dbg[0])
+	if err != nil {
+		return nil, fmt.Errorf("NewDatabase -> %w", err)
+	}
+	target, err := newEndpoint(opt.TargetType, opt.TargetSchema, opt.TargetDsn, opt.TargetHost, opt.TargetPort, opt.TargetUser, opt.TargetPassword, dbg[1])
+	if err != nil {
+		source.Conn.Close()
+		return nil, fmt.Errorf("NewDatabase -> %w", err)
+	}
+
+	db := Database{
+		SourceDb:   dbg[0],
+		TargetDb:   dbg[1],
+		SourceHost: opt.SourceHost,
+		TargetHost: opt.TargetHost,
+		SourcePort: opt.SourcePort,
+		TargetPort: opt.TargetPort,
+		Source:     source,
+		Target:     target,
+		Option:     opt,
+		Tables:     &model.TableInfo{},
+	}
+
+	db.Tables.ToCheck = opt.TableList
+	db.Tables.Skip = opt.SkipTableList
+
+	var i model.Database = &db
+	return i, nil
+}
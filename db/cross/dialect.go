@@ -0,0 +1,178 @@
+package cross
+
+import (
+	"checkData/util"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+/*
+跨数据库核对时，源端和目标端的sql语法不一样，由Dialect屏蔽差异
+mysql的schema即database，pgsql默认schema为public，mssql默认schema为dbo
+*/
+type Dialect interface {
+	Name() string
+	Quote() string
+	DefaultSchema() string
+	Open(host string, port int, user, password, database string) (*sql.DB, error)
+	TablesSQL(schema string) string
+	KeysSQL(schema, tb string) string
+	ColumnsSQL(schema, tb string) string
+	EncloseTbName(schema, tb string) string
+	EscapeValue(val string) string
+	BinaryLiteral(hexText string) string
+}
+
+func NewDialect(dbType string) (Dialect, error) {
+	switch dbType {
+	case "mysql", "doris", "tidb", "oceanbase":
+		return &mysqlDialect{name: dbType}, nil
+	case "pgsql":
+		return &pgsqlDialect{}, nil
+	case "mssql":
+		return &mssqlDialect{}, nil
+	default:
+		return nil, fmt.Errorf("NewDialect:Unsupported database type %s", dbType)
+	}
+}
+
+type mysqlDialect struct {
+	name string
+}
+
+func (self *mysqlDialect) Name() string {
+	return self.name
+}
+
+func (self *mysqlDialect) Quote() string {
+	return "`"
+}
+
+func (self *mysqlDialect) DefaultSchema() string {
+	return ""
+}
+
+func (self *mysqlDialect) Open(host string, port int, user, password, database string) (*sql.DB, error) {
+	if self.name == "oceanbase" {
+		return util.NewOceanbaseDB(host, port, user, password, database)
+	}
+	return util.NewMysqlDB(host, port, user, password, database)
+}
+
+func (self *mysqlDialect) TablesSQL(string) string {
+	return "select table_name from information_schema.tables where table_schema=database() and table_type='BASE TABLE' order by table_name"
+}
+
+func (self *mysqlDialect) KeysSQL(_, tb string) string {
+	return fmt.Sprintf("select column_name from information_schema.key_column_usage where table_schema=database() and table_name='%s' and constraint_name='PRIMARY' order by ordinal_position", self.EscapeValue(tb))
+}
+
+func (self *mysqlDialect) ColumnsSQL(_, tb string) string {
+	return fmt.Sprintf("select column_name from information_schema.columns where table_schema=database() and table_name='%s' order by ordinal_position", self.EscapeValue(tb))
+}
+
+func (self *mysqlDialect) EncloseTbName(_, tb string) string {
+	return util.EncloseStr(tb, self.Quote())
+}
+
+func (self *mysqlDialect) EscapeValue(val string) string {
+	// ' -> ''  \ -> \\
+	return strings.NewReplacer(`'`, `''`, `\`, `\\`).Replace(val)
+}
+
+func (self *mysqlDialect) BinaryLiteral(hexText string) string {
+	return fmt.Sprintf("X'%s'", hexText)
+}
+
+type pgsqlDialect struct{}
+
+func (self *pgsqlDialect) Name() string {
+	return "pgsql"
+}
+
+func (self *pgsqlDialect) Quote() string {
+	return `"`
+}
+
+func (self *pgsqlDialect) DefaultSchema() string {
+	return "public"
+}
+
+func (self *pgsqlDialect) Open(host string, port int, user, password, database string) (*sql.DB, error) {
+	return util.NewPgsqlDB(host, port, user, password, database)
+}
+
+func (self *pgsqlDialect) TablesSQL(schema string) string {
+	return fmt.Sprintf("select tablename from pg_tables where schemaname='%s' order by tablename", self.EscapeValue(schema))
+}
+
+func (self *pgsqlDialect) KeysSQL(schema, tb string) string {
+	return fmt.Sprintf(`select pg_attribute.attname as column_name from pg_index, pg_class, pg_attribute, pg_namespace
+where pg_namespace.oid = pg_class.relnamespace and pg_namespace.nspname = '%s' and pg_class.relname='%s' and indrelid = pg_class.oid and pg_attribute.attrelid = pg_class.oid and pg_attribute.attnum = any(pg_index.indkey) and indisprimary
+order by array_position(pg_index.indkey, pg_attribute.attnum)`, self.EscapeValue(schema), self.EscapeValue(tb))
+}
+
+func (self *pgsqlDialect) ColumnsSQL(schema, tb string) string {
+	return fmt.Sprintf(`select a.attname from pg_class c join pg_attribute a on a.attrelid = c.oid join pg_namespace n on n.oid = c.relnamespace
+where a.attnum > 0 and not a.attisdropped and n.nspname='%s' and c.relname = '%s' order by a.attnum`, self.EscapeValue(schema), self.EscapeValue(tb))
+}
+
+func (self *pgsqlDialect) EncloseTbName(schema, tb string) string {
+	return util.EncloseStr(schema, self.Quote()) + "." + util.EncloseStr(tb, self.Quote())
+}
+
+func (self *pgsqlDialect) EscapeValue(val string) string {
+	return strings.ReplaceAll(val, `'`, `''`)
+}
+
+func (self *pgsqlDialect) BinaryLiteral(hexText string) string {
+	return fmt.Sprintf("decode('%s','hex')", hexText)
+}
+
+type mssqlDialect struct{}
+
+func (self *mssqlDialect) Name() string {
+	return "mssql"
+}
+
+func (self *mssqlDialect) Quote() string {
+	return `"`
+}
+
+func (self *mssqlDialect) DefaultSchema() string {
+	return "dbo"
+}
+
+func (self *mssqlDialect) Open(host string, port int, user, password, database string) (*sql.DB, error) {
+	return util.NewMssqlDB(host, port, user, password, database)
+}
+
+func (self *mssqlDialect) TablesSQL(schema string) string {
+	return fmt.Sprintf("SELECT TABLE_NAME FROM INFORMATION_SCHEMA.TABLES WHERE TABLE_TYPE = 'BASE TABLE' AND TABLE_SCHEMA='%s' ORDER BY TABLE_NAME", self.EscapeValue(schema))
+}
+
+func (self *mssqlDialect) KeysSQL(schema, tb string) string {
+	//不同schema下的约束可以同名，KEY_COLUMN_USAGE也要按schema和表名过滤
+	return fmt.Sprintf(`SELECT COLUMN_NAME FROM INFORMATION_SCHEMA.KEY_COLUMN_USAGE
+where TABLE_SCHEMA='%[1]s' AND TABLE_NAME='%[2]s' AND CONSTRAINT_NAME in (
+SELECT CONSTRAINT_NAME FROM INFORMATION_SCHEMA.TABLE_CONSTRAINTS WHERE TABLE_SCHEMA='%[1]s' AND TABLE_NAME='%[2]s' AND CONSTRAINT_TYPE = 'PRIMARY KEY'
+)
+order by ORDINAL_POSITION`, self.EscapeValue(schema), self.EscapeValue(tb))
+}
+
+func (self *mssqlDialect) ColumnsSQL(schema, tb string) string {
+	return fmt.Sprintf(`select COLUMN_NAME from INFORMATION_SCHEMA.COLUMNS where TABLE_SCHEMA='%s' and TABLE_NAME='%s' order by ORDINAL_POSITION`, self.EscapeValue(schema), self.EscapeValue(tb))
+}
+
+func (self *mssqlDialect) EncloseTbName(schema, tb string) string {
+	return util.EncloseStr(schema, self.Quote()) + "." + util.EncloseStr(tb, self.Quote())
+}
+
+func (self *mssqlDialect) EscapeValue(val string) string {
+	return strings.ReplaceAll(val, `'`, `''`)
+}
+
+func (self *mssqlDialect) BinaryLiteral(hexText string) string {
+	return "0x" + hexText
+}
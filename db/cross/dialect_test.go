@@ -0,0 +1,23 @@
+package cross
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDialectEscapeNames(t *testing.T) {
+	//schema和表名中的引号需要转义
+	dialects := []Dialect{&mysqlDialect{name: "mysql"}, &pgsqlDialect{}, &mssqlDialect{}}
+	for _, d := range dialects {
+		for _, sql := range []string{d.TablesSQL("s'1"), d.KeysSQL("s'1", "t'1"), d.ColumnsSQL("s'1", "t'1")} {
+			if strings.Contains(strings.ReplaceAll(sql, "''", ""), "'1") {
+				t.Fatalf("[%s] 没有转义: %s", d.Name(), sql)
+			}
+		}
+	}
+
+	keys := (&mssqlDialect{}).KeysSQL("s1", "t1")
+	if !strings.Contains(keys, "KEY_COLUMN_USAGE\nwhere TABLE_SCHEMA='s1' AND TABLE_NAME='t1'") {
+		t.Fatalf("mssql KeysSQL 缺少schema条件: %s", keys)
+	}
+}
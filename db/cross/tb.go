@@ -0,0 +1,202 @@
+package cross
+
+import (
+	"checkData/model"
+	"checkData/util"
+	"fmt"
+	"github.com/gookit/slog"
+	"regexp"
+	"strings"
+)
+
+type Table struct {
+	DbName        string
+	TbName        string
+	TargetName    string            //目标端的表名，没有映射时和TbName相同
+	ColumnMap     map[string]string //源端列名 -> 目标端列名，没有映射的列按名称(不区分大小写)对应
+	Mode          string            //slow,count
+	Keys          []string
+	Columns       []string
+	TargetKeys    []string //和Keys一一对应的目标端列名
+	TargetColumns []string //和Columns一一对应的目标端列名
+	Where         string
+	TargetWhere   string //目标端的过滤条件，由Options.TargetFilter确定
+	SkipColumns   []string
+	SourceTbName  string
+	TargetTbName  string
+	SourceSQLText string
+	TargetSQLText string
+	Hash          string //摘要算法
+	hashFunc      func([]byte) string
+	keyKinds      [2][]util.ValueKind                //两端主键列的值类型，复核和修复SQL按类型把规范化后的主键值转换成这一端的字面量
+	recheckRows   map[model.Key][2]map[string]string //最后一次复核时查询到的两端数据，行不存在时为nil
+	DbGroup       *Database
+	Result        *model.Result
+}
+
+func (self *Table) GetDbName() string {
+	return self.DbName
+}
+
+func (self *Table) GetTbName() string {
+	return self.TbName
+}
+
+func (self *Table) getEnclosedTbName() {
+	self.SourceTbName = self.DbGroup.Source.Dialect.EncloseTbName(self.DbGroup.Source.Schema, self.TbName)
+	self.TargetTbName = self.DbGroup.Target.Dialect.EncloseTbName(self.DbGroup.Target.Schema, self.TargetName)
+}
+
+func (self *Table) getKeys() error {
+	if len(self.Keys) > 0 {
+		return nil
+	}
+
+	ep := self.DbGroup.Source
+	rows, err := util.QueryReturnList(ep.Conn, ep.Dialect.KeysSQL(ep.Schema, self.TbName))
+	if err != nil {
+		return fmt.Errorf("getKeys -> %w", err)
+	}
+
+	for _, row := range rows {
+		self.Keys = append(self.Keys, row[0])
+	}
+
+	slog.Infof("[%s.%s] 主键列: %s", self.DbName, self.TbName, strings.Join(self.Keys, ", "))
+	return nil
+}
+
+func (self *Table) getColumns() error {
+	// 获取两端的列名，按名称(不区分大小写)对应
+	ep := self.DbGroup.Source
+	rows, err := util.QueryReturnList(ep.Conn, ep.Dialect.ColumnsSQL(ep.Schema, self.TbName))
+	if err != nil {
+		return fmt.Errorf("getColumns -> %w", err)
+	}
+	for _, row := range rows {
+		self.Columns = append(self.Columns, row[0])
+	}
+
+	ep = self.DbGroup.Target
+	rows, err = util.QueryReturnList(ep.Conn, ep.Dialect.ColumnsSQL(ep.Schema, self.TargetName))
+	if err != nil {
+		return fmt.Errorf("getColumns -> %w", err)
+	}
+	targetColumns := make(map[string]string, len(rows))
+	for _, row := range rows {
+		targetColumns[strings.ToLower(row[0])] = row[0]
+	}
+
+	var lost []string
+	self.TargetKeys = make([]string, 0, len(self.Keys))
+	for _, k := range model.MapNames(self.Keys, self.ColumnMap) {
+		v, ok := targetColumns[strings.ToLower(k)]
+		if !ok {
+			lost = append(lost, k)
+		}
+		self.TargetKeys = append(self.TargetKeys, v)
+	}
+	self.TargetColumns = make([]string, 0, len(self.Columns))
+	names := model.MapNames(self.Columns, self.ColumnMap)
+	for i, c := range self.Columns {
+		v, ok := targetColumns[strings.ToLower(names[i])]
+		if !ok && !util.InSlice(c, self.SkipColumns) && !util.InSlice(c, self.Keys) {
+			lost = append(lost, names[i])
+		}
+		self.TargetColumns = append(self.TargetColumns, v)
+	}
+
+	if len(lost) > 0 {
+		return fmt.Errorf("getColumns:Target端缺少列 %s", strings.Join(lost, ", "))
+	}
+	return nil
+}
+
+func (self *Table) getCheckSQL() error {
+	sq := self.DbGroup.Source.Dialect.Quote()
+	tq := self.DbGroup.Target.Dialect.Quote()
+
+	sourceSQL := fmt.Sprintf("select %s, %s from %s", util.EncloseAndJoin(self.Keys, sq), util.EncloseAndJoin(self.Columns, sq), self.SourceTbName)
+	targetSQL := fmt.Sprintf("select %s, %s from %s", util.EncloseAndJoin(self.TargetKeys, tq), util.EncloseAndJoin(self.TargetColumns, tq), self.TargetTbName)
+
+	if self.Where != "" {
+		sourceSQL += " where " + self.Where
+	}
+	if where := self.getTargetWhere(); where != "" {
+		targetSQL += " where " + where
+	}
+	self.SourceSQLText = sourceSQL + " order by " + util.EncloseAndJoin(self.Keys, sq)
+	self.TargetSQLText = targetSQL + " order by " + util.EncloseAndJoin(self.TargetKeys, tq)
+
+	return nil
+}
+
+func (self *Table) getKeyKinds() (err error) {
+	//只查询结果集的列类型，不返回数据
+	query := func(side *Endpoint, tbName string, keys []string) ([]util.ValueKind, error) {
+		cur, err := side.Conn.Query(fmt.Sprintf("select %s from %s where 1=0", util.EncloseAndJoin(keys, side.Dialect.Quote()), tbName))
+		if err != nil {
+			return nil, err
+		}
+		defer cur.Close()
+		return util.GetValueKinds(cur)
+	}
+	if self.keyKinds[0], err = query(self.DbGroup.Source, self.SourceTbName, self.Keys); err != nil {
+		return fmt.Errorf("getKeyKinds:Source端 -> %w", err)
+	}
+	if self.keyKinds[1], err = query(self.DbGroup.Target, self.TargetTbName, self.TargetKeys); err != nil {
+		return fmt.Errorf("getKeyKinds:Target端 -> %w", err)
+	}
+	return nil
+}
+
+var numberLiteral = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?([eE][-+]?[0-9]+)?$`)
+
+func keyLiteral(dialect Dialect, value string, kind util.ValueKind) string {
+	//规范化后的主键值转换成这一端的字面量，由数据库转换成列的类型
+	switch kind {
+	case util.KindBinary:
+		return dialect.BinaryLiteral(value)
+	case util.KindNumber, util.KindFloat:
+		if numberLiteral.MatchString(value) {
+			return value
+		}
+	case util.KindBool:
+		//pgsql的boolean不能和数字比较，使用'1'、'0'
+		if _, ok := dialect.(*pgsqlDialect); !ok && numberLiteral.MatchString(value) {
+			return value
+		}
+	}
+	return "'" + dialect.EscapeValue(value) + "'"
+}
+
+func (self *Table) getTargetWhere() string {
+	return self.TargetWhere
+}
+
+func (self *Table) selectList(side *Endpoint, columns []string) string {
+	//目标端的列使用源端列名作为别名，方便两端结果对比
+	q := side.Dialect.Quote()
+	list := make([]string, 0, len(columns))
+	for i, c := range columns {
+		if side == self.DbGroup.Target {
+			list = append(list, util.EncloseStr(self.TargetColumns[i], q)+" as "+util.EncloseStr(c, q))
+		} else {
+			list = append(list, util.EncloseStr(c, q))
+		}
+	}
+	return strings.Join(list, ", ")
+}
+
+func (self *Table) encloseValues(row []any, kinds []util.ValueKind, dialect Dialect) []string {
+	//生成目标端修复SQL使用的值，二进制使用十六进制字面量
+	list := make([]string, 0, len(row))
+	for i := range row {
+		if row[i] != nil && kinds[i] == util.KindBinary {
+			list = append(list, dialect.BinaryLiteral(row[i].(string)))
+		} else {
+			list = append(list, util.EncloseValue(row[i], dialect.EscapeValue))
+		}
+	}
+	return list
+}
@@ -0,0 +1,53 @@
+package doris
+
+import (
+	"checkData/model"
+	"checkData/util"
+	"fmt"
+	"github.com/gookit/slog"
+	"time"
+)
+
+func (self *Table) initRules() {
+	//规范化规则只作用于核对的非主键列，目标端的规则不做时间偏移
+	for c, rule := range self.Rules {
+		if !util.InSlice(c, self.Columns) {
+			slog.Warnf("[%s.%s] 列%s不存在、是主键列或被跳过，忽略规范化规则: %s", self.DbName, self.TbName, c, rule)
+		}
+	}
+	self.sourceRules = model.RuleList(self.Columns, self.Rules)
+	self.targetRules = make([]*model.ColumnRule, len(self.sourceRules))
+	for i, rule := range self.sourceRules {
+		if rule != nil {
+			self.targetRules[i] = rule.ForTarget()
+			slog.Infof("[%s.%s] 列%s的规范化规则: %s", self.DbName, self.TbName, self.Columns[i], rule)
+		}
+	}
+}
+
+func ruleExpr(col string, rule *model.ColumnRule) string {
+	//数据库侧的规范化表达式，执行顺序和结果与model.ColumnRule.Apply一致
+	if rule == nil {
+		return col
+	}
+	if rule.Rtrim {
+		col = fmt.Sprintf("rtrim(%s)", col)
+	}
+	if rule.Lower {
+		col = fmt.Sprintf("lower(%s)", col)
+	}
+	if rule.EmptyNull {
+		col = fmt.Sprintf("nullif(%s,'')", col)
+	}
+	if rule.Shift != 0 {
+		col = fmt.Sprintf("date_add(%s,interval %d second)", col, int64(rule.Shift/time.Second))
+	}
+	if rule.Trunc != "" {
+		size, suffix := model.TruncLayout(rule.Trunc)
+		col = fmt.Sprintf("concat(left(date_format(%s,'%%Y-%%m-%%d %%H:%%i:%%s'),%d),'%s')", col, size, suffix)
+	}
+	if rule.Round >= 0 {
+		col = fmt.Sprintf("cast(round(%s,%d) as decimal(38,%d))", col, rule.Round, rule.Round)
+	}
+	return col
+}
@@ -0,0 +1,132 @@
+package doris
+
+import (
+	"checkData/model"
+	"checkData/util"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var rawDefault = regexp.MustCompile(`(?i)^(-?[0-9.]+|null|current_timestamp.*|'.*'|\(.*\))$`)
+
+func (self *Table) getTableDef(conn *sql.DB, tbName string) (*model.TableDef, error) {
+	//从information_schema读取表结构
+	sqlText := fmt.Sprintf("select COLUMN_NAME, COLUMN_TYPE, IS_NULLABLE, COLUMN_DEFAULT, EXTRA, COLUMN_COMMENT from information_schema.COLUMNS where TABLE_SCHEMA = database() and TABLE_NAME = '%s' order by ORDINAL_POSITION", self.escapeValue(tbName))
+	rows, err := util.QueryReturnListWithNil(conn, sqlText)
+	if err != nil {
+		return nil, fmt.Errorf("getTableDef -> %w", err)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("getTableDef:表%s不存在", tbName)
+	}
+	def := &model.TableDef{Columns: model.BuildColumns(rows)}
+	for i, row := range rows {
+		setColumnAttrs(&def.Columns[i], row[4:])
+	}
+
+	sqlText = fmt.Sprintf("select INDEX_NAME, NON_UNIQUE = 0, INDEX_NAME = 'PRIMARY', COLUMN_NAME from information_schema.STATISTICS where TABLE_SCHEMA = database() and TABLE_NAME = '%s' order by INDEX_NAME, SEQ_IN_INDEX", self.escapeValue(tbName))
+	list, err := util.QueryReturnList(conn, sqlText)
+	if err != nil {
+		return nil, fmt.Errorf("getTableDef -> %w", err)
+	}
+	def.Indexes = model.BuildIndexes(list)
+	return def, nil
+}
+
+func setColumnAttrs(c *model.ColumnDef, row []any) {
+	//EXTRA, COLUMN_COMMENT，MODIFY COLUMN时需要带上注释，否则会丢失
+	text := func(v any) string {
+		if v == nil {
+			return ""
+		}
+		return fmt.Sprint(v)
+	}
+	c.Comment = text(row[1])
+
+	//doris的MODIFY COLUMN不支持修改自增等属性，有这些属性的列需要人工确认
+	c.Extra = strings.ToUpper(strings.TrimSpace(text(row[0])))
+	c.Manual = c.Extra != ""
+}
+
+func (self *Table) GetTableDef() (source *model.TableDef, target *model.TableDef, err error) {
+	self.getEnclosedTbName()
+	source, err = self.getTableDef(self.DbGroup.SourceDbConn, self.TbName)
+	if err != nil {
+		return nil, nil, fmt.Errorf("GetTableDef:source -> %w", err)
+	}
+	target, err = self.getTableDef(self.DbGroup.TargetDbConn, self.TargetTbName)
+	if err != nil {
+		return nil, nil, fmt.Errorf("GetTableDef:target -> %w", err)
+	}
+	return source, target, nil
+}
+
+func (self *Table) GetAlterDDL(diffs []model.SchemaDiff) string {
+	//生成使目标端和源端一致的DDL，删除列和索引的语句被注释，需要确认后再执行
+	var buf strings.Builder
+	modified := make(map[string]bool)
+	for _, d := range diffs {
+		name := util.EncloseStr(d.Name, quote)
+		switch d.Kind {
+		case "column_missing":
+			buf.WriteString(self.manualComment(d.Column, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s;\n", self.EnclosedTargetTbName, name, self.getColumnDefText(d.Column))))
+		case "column_type", "column_nullable", "column_default":
+			//同一列的多处差异只需要一条语句
+			if modified[d.Name] {
+				continue
+			}
+			modified[d.Name] = true
+			buf.WriteString(self.manualComment(d.Column, fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s %s;\n", self.EnclosedTargetTbName, name, self.getColumnDefText(d.Column))))
+		case "column_extra":
+			buf.WriteString(fmt.Sprintf("-- ALTER TABLE %s DROP COLUMN %s;\n", self.EnclosedTargetTbName, name))
+		case "primary_key":
+			var list []string
+			if d.Name != "" {
+				list = append(list, "DROP PRIMARY KEY")
+			}
+			if d.Index != nil {
+				list = append(list, fmt.Sprintf("ADD PRIMARY KEY (%s)", util.EncloseAndJoin(d.Index.Columns, quote)))
+			}
+			buf.WriteString(fmt.Sprintf("ALTER TABLE %s %s;\n", self.EnclosedTargetTbName, strings.Join(list, ", ")))
+		case "index_missing":
+			unique := ""
+			if d.Index.Unique {
+				unique = "UNIQUE "
+			}
+			buf.WriteString(fmt.Sprintf("ALTER TABLE %s ADD %sINDEX %s (%s);\n", self.EnclosedTargetTbName, unique, name, util.EncloseAndJoin(d.Index.Columns, quote)))
+		case "index_extra":
+			buf.WriteString(fmt.Sprintf("-- ALTER TABLE %s DROP INDEX %s;\n", self.EnclosedTargetTbName, name))
+		}
+	}
+	return buf.String()
+}
+
+func (self *Table) manualComment(c *model.ColumnDef, ddl string) string {
+	//自增等属性无法通过MODIFY COLUMN还原，注释后需要按源端的定义修改
+	if !c.Manual {
+		return ddl
+	}
+	return fmt.Sprintf("-- %s 有无法还原的属性 %s，需要按源端的定义修改\n-- %s", c.Name, c.Extra, ddl)
+}
+
+func (self *Table) getColumnDefText(c *model.ColumnDef) string {
+	//类型 [NOT NULL] [DEFAULT] [COMMENT]
+	text := c.Type
+	if !c.Nullable {
+		text += " NOT NULL"
+	}
+	if c.Default != nil {
+		//COLUMN_DEFAULT中的字符串默认值不带引号，数字和表达式原样使用
+		if rawDefault.MatchString(*c.Default) {
+			text += " DEFAULT " + *c.Default
+		} else {
+			text += " DEFAULT '" + self.escapeValue(*c.Default) + "'"
+		}
+	}
+	if c.Comment != "" {
+		text += " COMMENT '" + self.escapeValue(c.Comment) + "'"
+	}
+	return text
+}
@@ -0,0 +1,119 @@
+package mongo
+
+import (
+	"bytes"
+	"checkData/util"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+)
+
+// Canonical 把文档转换成和字段顺序无关的规范格式再计算摘要
+// 文档的字段按名称排序；LooseNumeric为true时int32/int64/double/decimal128按数值比较，如 1 和 NumberLong(1) 一致；
+// UnorderedArrays为true时数组按元素排序，即数组元素的顺序不影响核对结果
+type Canonical struct {
+	LooseNumeric    bool
+	UnorderedArrays bool
+}
+
+func (self *Canonical) Encode(raw bson.Raw) ([]byte, error) {
+	return self.appendDocument(nil, raw)
+}
+
+func (self *Canonical) appendDocument(buf []byte, raw bson.Raw) ([]byte, error) {
+	elements, err := raw.Elements()
+	if err != nil {
+		return nil, fmt.Errorf("appendDocument -> %w", err)
+	}
+	sort.Slice(elements, func(i, j int) bool {
+		return elements[i].Key() < elements[j].Key()
+	})
+
+	buf = append(buf, '{')
+	for _, e := range elements {
+		buf = util.AppendEncodedValue(buf, []byte(e.Key()), false)
+		buf, err = self.appendValue(buf, e.Value())
+		if err != nil {
+			return nil, err
+		}
+	}
+	return append(buf, '}'), nil
+}
+
+func (self *Canonical) appendArray(buf []byte, raw bson.Raw) ([]byte, error) {
+	values, err := raw.Values()
+	if err != nil {
+		return nil, fmt.Errorf("appendArray -> %w", err)
+	}
+
+	items := make([][]byte, 0, len(values))
+	for _, v := range values {
+		item, err := self.appendValue(nil, v)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	if self.UnorderedArrays {
+		sort.Slice(items, func(i, j int) bool {
+			return bytes.Compare(items[i], items[j]) < 0
+		})
+	}
+
+	buf = append(buf, '[')
+	for _, item := range items {
+		buf = util.AppendEncodedValue(buf, item, false)
+	}
+	return append(buf, ']'), nil
+}
+
+func (self *Canonical) appendValue(buf []byte, v bson.RawValue) ([]byte, error) {
+	//每个值带类型标记，数值宽松比较时统一标记为n
+	switch v.Type {
+	case bsontype.EmbeddedDocument:
+		return self.appendDocument(buf, v.Document())
+	case bsontype.Array:
+		return self.appendArray(buf, v.Array())
+	case bsontype.Int32, bsontype.Int64, bsontype.Double, bsontype.Decimal128:
+		if self.LooseNumeric {
+			text, err := numericText(v)
+			if err != nil {
+				return nil, err
+			}
+			buf = append(buf, 'n')
+			return util.AppendEncodedValue(buf, []byte(text), false), nil
+		}
+	}
+	buf = append(buf, byte(v.Type))
+	return util.AppendEncodedValue(buf, v.Value, false), nil
+}
+
+func numericText(v bson.RawValue) (string, error) {
+	//数值转换成最简分数，double按最短的十进制表示转换，如 0.1 和 NumberDecimal("0.10") 一致
+	var text string
+	switch v.Type {
+	case bsontype.Int32:
+		return strconv.FormatInt(int64(v.Int32()), 10), nil
+	case bsontype.Int64:
+		return strconv.FormatInt(v.Int64(), 10), nil
+	case bsontype.Double:
+		f := v.Double()
+		if math.IsInf(f, 0) || math.IsNaN(f) {
+			return strconv.FormatFloat(f, 'g', -1, 64), nil
+		}
+		text = strconv.FormatFloat(f, 'g', -1, 64)
+	case bsontype.Decimal128:
+		text = v.Decimal128().String()
+	}
+
+	r, ok := new(big.Rat).SetString(text)
+	if !ok {
+		//NaN、Infinity等
+		return text, nil
+	}
+	return r.RatString(), nil
+}
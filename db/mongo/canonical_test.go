@@ -0,0 +1,49 @@
+package mongo
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"testing"
+)
+
+func encodeDoc(t *testing.T, c *Canonical, doc bson.D) string {
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf, err := c.Encode(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(buf)
+}
+
+func TestCanonical(t *testing.T) {
+	dec, _ := primitive.ParseDecimal128("1.50")
+	cases := []struct {
+		canonical *Canonical
+		a, b      bson.D
+		same      bool
+	}{
+		//字段顺序
+		{&Canonical{}, bson.D{{Key: "a", Value: 1}, {Key: "b", Value: "x"}}, bson.D{{Key: "b", Value: "x"}, {Key: "a", Value: 1}}, true},
+		{&Canonical{}, bson.D{{Key: "s", Value: bson.D{{Key: "x", Value: 1}, {Key: "y", Value: 2}}}}, bson.D{{Key: "s", Value: bson.D{{Key: "y", Value: 2}, {Key: "x", Value: 1}}}}, true},
+		//数值类型
+		{&Canonical{}, bson.D{{Key: "n", Value: int32(1)}}, bson.D{{Key: "n", Value: int64(1)}}, false},
+		{&Canonical{LooseNumeric: true}, bson.D{{Key: "n", Value: int32(1)}}, bson.D{{Key: "n", Value: int64(1)}}, true},
+		{&Canonical{LooseNumeric: true}, bson.D{{Key: "n", Value: 1.5}}, bson.D{{Key: "n", Value: dec}}, true},
+		{&Canonical{LooseNumeric: true}, bson.D{{Key: "n", Value: 1}}, bson.D{{Key: "n", Value: "1"}}, false},
+		//数组顺序
+		{&Canonical{}, bson.D{{Key: "a", Value: bson.A{1, 2}}}, bson.D{{Key: "a", Value: bson.A{2, 1}}}, false},
+		{&Canonical{UnorderedArrays: true}, bson.D{{Key: "a", Value: bson.A{1, "x", 2}}}, bson.D{{Key: "a", Value: bson.A{2, 1, "x"}}}, true},
+		//字段名和值的边界
+		{&Canonical{}, bson.D{{Key: "ab", Value: "c"}}, bson.D{{Key: "a", Value: "bc"}}, false},
+	}
+
+	for i, c := range cases {
+		same := encodeDoc(t, c.canonical, c.a) == encodeDoc(t, c.canonical, c.b)
+		if same != c.same {
+			t.Fatalf("case %d: %v vs %v same=%v, want %v", i, c.a, c.b, same, c.same)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package mongo
+
+import (
+	"checkData/model"
+	"context"
+	"fmt"
+	"github.com/gookit/slog"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"io"
+)
+
+// ChangeStreamSource 监听源端数据库的change stream，返回需要核对的集合中有变更的_id，源端需要是副本集或分片集群
+type ChangeStreamSource struct {
+	stream *mongo.ChangeStream
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func NewChangeStreamSource(db model.Database, opt *model.Options) (*ChangeStreamSource, error) {
+	mdb, ok := db.(*Database)
+	if !ok {
+		return nil, fmt.Errorf("NewChangeStreamSource:unsupported database %T", db)
+	}
+
+	//只监听需要核对的集合的数据变更
+	collections := bson.A{}
+	for _, tb := range mdb.Tables.ToCheck {
+		collections = append(collections, tb)
+	}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.D{
+			{Key: "ns.coll", Value: bson.D{{Key: "$in", Value: collections}}},
+			{Key: "operationType", Value: bson.D{{Key: "$in", Value: bson.A{"insert", "update", "replace", "delete"}}}},
+		}}},
+		{{Key: "$project", Value: bson.D{{Key: "ns", Value: 1}, {Key: "documentKey", Value: 1}}}},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := mdb.SourceDbConn.Db(mdb.SourceDb).Watch(ctx, pipeline)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("NewChangeStreamSource -> %w", err)
+	}
+	slog.Infof("[%s] 开始监听change stream [集合数:%d]", mdb.SourceDb, len(collections))
+
+	return &ChangeStreamSource{stream: stream, ctx: ctx, cancel: cancel}, nil
+}
+
+func (self *ChangeStreamSource) Next() (*model.Change, error) {
+	for self.stream.Next(self.ctx) {
+		change, err := changeFromEvent(self.stream.Current)
+		if err != nil {
+			slog.Warnf("解析change stream事件报错，忽略这个变更：%s", err)
+			continue
+		}
+		return change, nil
+	}
+	if err := self.stream.Err(); err != nil && self.ctx.Err() == nil {
+		return nil, fmt.Errorf("ChangeStreamSource.Next -> %w", err)
+	}
+	return nil, io.EOF
+}
+
+func (self *ChangeStreamSource) Close() error {
+	self.cancel()
+	return self.stream.Close(context.TODO())
+}
+
+func changeFromEvent(event bson.Raw) (*model.Change, error) {
+	//_id使用和全量核对相同的Extended JSON格式
+	coll, ok := event.Lookup("ns", "coll").StringValueOK()
+	if !ok {
+		return nil, fmt.Errorf("changeFromEvent:缺少ns.coll")
+	}
+	id, err := event.LookupErr("documentKey", "_id")
+	if err != nil {
+		return nil, fmt.Errorf("changeFromEvent:缺少documentKey._id")
+	}
+	return &model.Change{Table: coll, Key: model.NewKey([]any{id.String()})}, nil
+}
@@ -0,0 +1,34 @@
+package mongo
+
+import (
+	"checkData/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"testing"
+)
+
+func TestChangeFromEvent(t *testing.T) {
+	oid, _ := primitive.ObjectIDFromHex("65300d2f1c9d440000a1b2c3")
+	doc := bson.D{{Key: "_id", Value: oid}, {Key: "name", Value: "a"}}
+	raw, _ := bson.Marshal(doc)
+
+	event, _ := bson.Marshal(bson.D{
+		{Key: "ns", Value: bson.D{{Key: "db", Value: "db1"}, {Key: "coll", Value: "users"}}},
+		{Key: "documentKey", Value: bson.D{{Key: "_id", Value: oid}}},
+	})
+	change, err := changeFromEvent(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//和全量核对时的主键格式一致
+	want := model.NewKey([]any{bson.Raw(raw).Lookup("_id").String()})
+	if change.Table != "users" || change.Key != want {
+		t.Fatalf("got %+v, want users %s", change, want)
+	}
+
+	event, _ = bson.Marshal(bson.D{{Key: "ns", Value: bson.D{{Key: "coll", Value: "users"}}}})
+	if _, err = changeFromEvent(event); err == nil {
+		t.Fatal("expected error for an event without documentKey")
+	}
+}
@@ -0,0 +1,159 @@
+package mongo
+
+import (
+	"checkData/model"
+	"checkData/util"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gookit/slog"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (self *Table) getChunkFilter(where bson.D) (bson.D, error) {
+	//合并where参数和数据块的范围条件，边界值为Extended JSON格式
+	if self.Chunk == nil {
+		return where, nil
+	}
+
+	cond := bson.D{}
+	if self.Chunk.Lower != nil {
+		v, err := parseExtValue(*self.Chunk.Lower)
+		if err != nil {
+			return nil, fmt.Errorf("getChunkFilter -> %w", err)
+		}
+		cond = append(cond, bson.E{Key: "$gte", Value: v})
+	}
+	if self.Chunk.Upper != nil {
+		v, err := parseExtValue(*self.Chunk.Upper)
+		if err != nil {
+			return nil, fmt.Errorf("getChunkFilter -> %w", err)
+		}
+		cond = append(cond, bson.E{Key: "$lt", Value: v})
+	}
+	if len(cond) == 0 {
+		return where, nil
+	}
+	return andFilter(where, bson.D{{Key: self.Keys[0], Value: cond}}), nil
+}
+
+func typeClass(t bsontype.Type) bsontype.Type {
+	//$gte/$lt可以跨类型比较的数值和字符串各算一类
+	switch t {
+	case bsontype.Int32, bsontype.Int64, bsontype.Decimal128:
+		return bsontype.Double
+	case bsontype.Symbol:
+		return bsontype.String
+	}
+	return t
+}
+
+func keyTypeClass(tb *mongo.Collection, filter bson.D, key string, projection bson.D) (t bsontype.Type, mixed bool, err error) {
+	//按键排序后的最小值和最大值类型相同时，所有文档的键都是这一类型(BSON按类型排序)，键不存在或为null时排在最前面
+	//没有文档时返回的类型为0
+	var types []bsontype.Type
+	for _, order := range []int{1, -1} {
+		findOptions := options.FindOne().SetSort(bson.D{{Key: key, Value: order}}).SetProjection(projection)
+		raw, err := tb.FindOne(context.TODO(), filter, findOptions).DecodeBytes()
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return 0, false, nil
+		} else if err != nil {
+			return 0, false, fmt.Errorf("keyTypeClass -> %w", err)
+		}
+		v, err := raw.LookupErr(key)
+		if err != nil {
+			return 0, true, nil
+		}
+		switch v.Type {
+		case bsontype.Null, bsontype.Undefined, bsontype.MinKey, bsontype.MaxKey, bsontype.Array:
+			return 0, true, nil
+		}
+		types = append(types, typeClass(v.Type))
+	}
+	return types[0], types[0] != types[1], nil
+}
+
+func (self *Table) isKeyMixed(key string, projection bson.D) (bool, error) {
+	//两端的键都是同一类型时才能按范围拆分
+	source := self.DbGroup.SourceDbConn.Tb(self.DbGroup.SourceDb, self.TbName)
+	st, mixed, err := keyTypeClass(source, self.filter, key, projection)
+	if err != nil || mixed {
+		return mixed, err
+	}
+	target := self.DbGroup.TargetDbConn.Tb(self.DbGroup.TargetDb, self.TargetTbName)
+	tt, mixed, err := keyTypeClass(target, self.targetFilter, key, projection)
+	if err != nil || mixed {
+		return mixed, err
+	}
+	return st != 0 && tt != 0 && st != tt, nil
+}
+
+func (self *Table) GetChunks(size int) ([]*model.Chunk, error) {
+	//按第一个键拆分数据块，每块大约size行，使用skip在索引上定位边界，第一个键的值重复较多时，数据块会偏大
+	defer util.TimeCost()(fmt.Sprintf("[%s.%s] 拆分数据块完成", self.DbName, self.TbName))
+
+	tb := self.DbGroup.SourceDbConn.Tb(self.DbGroup.SourceDb, self.TbName)
+	key := self.Keys[0]
+	projection := bson.D{{Key: key, Value: 1}}
+	if key != "_id" {
+		projection = append(projection, bson.E{Key: "_id", Value: 0})
+	}
+	findOptions := options.FindOne().
+		SetSort(bson.D{{Key: key, Value: 1}}).
+		SetProjection(projection).
+		SetSkip(int64(size - 1))
+
+	//$gte/$lt只匹配相同类型的值，键有多种类型、为null或不存在时，按范围拆分会漏掉部分文档
+	mixed, err := self.isKeyMixed(key, projection)
+	if err != nil {
+		return nil, fmt.Errorf("GetChunks -> %w", err)
+	}
+	if mixed {
+		slog.Warnf("[%s.%s] %s的值有多种类型、为null或不存在(包括目标端)，不拆分数据块", self.DbName, self.TbName, key)
+		return []*model.Chunk{{Index: 0}}, nil
+	}
+
+	var chunks []*model.Chunk
+	var lower *string
+	for {
+		filter := self.filter
+		if lower != nil {
+			v, err := parseExtValue(*lower)
+			if err != nil {
+				return nil, fmt.Errorf("GetChunks -> %w", err)
+			}
+			filter = self.withWhere(bson.D{{Key: key, Value: bson.D{{Key: "$gt", Value: v}}}})
+		}
+
+		raw, err := tb.FindOne(context.TODO(), filter, findOptions).DecodeBytes()
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("GetChunks -> %w", err)
+		}
+		v, err := raw.LookupErr(key)
+		if err != nil {
+			//第一个键不存在的文档无法按范围拆分
+			return nil, fmt.Errorf("GetChunks:文档缺少字段%s", key)
+		}
+
+		upper := v.String()
+		chunks = append(chunks, &model.Chunk{Index: len(chunks), Lower: lower, Upper: &upper})
+		lower = &upper
+	}
+	chunks = append(chunks, &model.Chunk{Index: len(chunks), Lower: lower})
+
+	slog.Infof("[%s.%s] 按 %s 拆分为 %d 个数据块", self.DbName, self.TbName, key, len(chunks))
+	return chunks, nil
+}
+
+func (self *Table) NewChunkTable(chunk *model.Chunk) model.Table {
+	//复制预检查后的表对象，只核对数据块范围内的数据
+	tb := *self
+	tb.Chunk = chunk
+	tb.Result = &model.Result{DbName: self.DbName, TbName: self.TbName, RecheckPassRows: -1}
+	return &tb
+}
@@ -43,6 +43,7 @@ func (self *Database) getTables() (err error) {
 	res2, err := self.TargetDbConn.ListCollectionNames(self.TargetDb)
 	if err != nil {
 		slog.Errorf("[%s:%s] 获取表名失败，%s", self.SourceDb, self.TargetDb, err)
+		return
 	}
 
 	//剔除系统表
@@ -52,7 +53,7 @@ func (self *Database) getTables() (err error) {
 			filtRes2 = append(filtRes2, v)
 		}
 	}
-	self.Tables.Target = filtRes1
+	self.Tables.Target = filtRes2
 	return nil
 
 }
@@ -64,9 +65,12 @@ func (self *Database) PreCheck() (err error) {
 			return fmt.Errorf("GetToCheck -> %w", err)
 		}
 
-		//目标库不存在的表
+		//目标库不存在的表，源端表名按映射规则转换成目标端表名后再匹配
+		var mapped []string
 		for _, t := range self.Tables.Source {
-			if !util.InSlice(t, self.Tables.Target) {
+			tt := self.Option.TargetTable(t)
+			mapped = append(mapped, tt)
+			if !util.InSlice(tt, self.Tables.Target) {
 				self.Tables.SourceMore = append(self.Tables.SourceMore, t)
 			} else {
 				self.Tables.ToCheck = append(self.Tables.ToCheck, t)
@@ -75,7 +79,7 @@ func (self *Database) PreCheck() (err error) {
 
 		//源库不存在的表
 		for _, t := range self.Tables.Target {
-			if !util.InSlice(t, self.Tables.Source) {
+			if !util.InSlice(t, mapped) {
 				self.Tables.TargetMore = append(self.Tables.TargetMore, t)
 			}
 		}
@@ -99,15 +103,25 @@ func (self *Database) GetTableInfo() *model.TableInfo {
 }
 
 func (self *Database) NewTable(tb string) model.Table {
+	var canonical *Canonical
+	if self.Option.DocHash == "canonical" {
+		canonical = &Canonical{LooseNumeric: self.Option.NumericTypes != "strict", UnorderedArrays: self.Option.UnorderedArrays}
+	}
+	opt := self.Option.ForTable(tb)
 	return &Table{
-		DbName:      self.TargetDb,
-		TbName:      tb,
-		Mode:        self.Option.Mode,
-		SkipColumns: self.Option.SkipColList,
-		Keys:        self.Option.KeysList,
-		Where:       self.Option.Where,
-		DbGroup:     self,
-		Result:      &model.Result{DbName: self.TargetDb, TbName: tb, RecheckPassRows: -1},
+		DbName:       self.TargetDb,
+		TbName:       tb,
+		TargetTbName: self.Option.TargetTable(tb),
+		Mode:         self.Option.Mode,
+		SkipColumns:  opt.SkipColList,
+		Keys:         opt.KeysList,
+		Where:        opt.SourceFilter(),
+		TargetWhere:  opt.TargetFilter(),
+		Hash:         self.Option.Hash,
+		Canonical:    canonical,
+		ServerDigest: self.Option.ServerDigest,
+		DbGroup:      self,
+		Result:       &model.Result{DbName: self.TargetDb, TbName: tb, RecheckPassRows: -1},
 	}
 }
 
@@ -127,8 +141,8 @@ func NewDatabase(opt *model.Options, dbg [2]string) (model.Database, error) {
 		TargetHost:   opt.TargetHost,
 		SourcePort:   opt.SourcePort,
 		TargetPort:   opt.TargetPort,
-		SourceDbConn: &util.MongoDB{Host: opt.SourceHost, Port: opt.SourcePort, User: opt.User, Password: opt.Password, Database: dbg[0]},
-		TargetDbConn: &util.MongoDB{Host: opt.TargetHost, Port: opt.TargetPort, User: opt.TargetUser, Password: opt.TargetPassword, Database: dbg[1]},
+		SourceDbConn: &util.MongoDB{Host: opt.SourceHost, Port: opt.SourcePort, User: opt.User, Password: opt.Password, Database: dbg[0], Uri: opt.SourceDsn},
+		TargetDbConn: &util.MongoDB{Host: opt.TargetHost, Port: opt.TargetPort, User: opt.TargetUser, Password: opt.TargetPassword, Database: dbg[1], Uri: opt.TargetDsn},
 		Option:       opt,
 		Tables:       &model.TableInfo{},
 	}
@@ -0,0 +1,127 @@
+package mongo
+
+import (
+	"checkData/model"
+	"checkData/util"
+	"context"
+	"fmt"
+	"github.com/gookit/slog"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// fastSumField fast模式下摘要的字段名
+const fastSumField = "__checkdata_sum"
+
+// fastSumFunction 在服务端计算文档的crc32，文档先转换成JSON字符串再按UTF-8编码，需要mongodb 4.4+且开启了服务端js
+// JSON字符串不包含类型信息，数值类型、ObjectId和字符串等JSON相同的值无法区分，所以只在指定了--server-digest时使用
+const fastSumFunction = `function(doc) {
+	var s = unescape(encodeURIComponent(JSON.stringify(doc)));
+	var crc = -1;
+	for (var i = 0; i < s.length; i++) {
+		crc ^= s.charCodeAt(i);
+		for (var k = 0; k < 8; k++) {
+			crc = (crc >>> 1) ^ (0xEDB88320 & -(crc & 1));
+		}
+	}
+	return ((crc ^ -1) >>> 0).toString(16);
+}`
+
+func (self *Table) getSumStage() bson.D {
+	// {$project: {k1: 1, k2: 1, __checkdata_sum: {$function: ...}}}
+	project := bson.D{}
+	for _, k := range self.Keys {
+		project = append(project, bson.E{Key: k, Value: 1})
+	}
+	if !util.InSlice("_id", self.Keys) {
+		project = append(project, bson.E{Key: "_id", Value: 0})
+	}
+	project = append(project, bson.E{Key: fastSumField, Value: bson.D{{Key: "$function", Value: bson.D{
+		{Key: "body", Value: fastSumFunction},
+		{Key: "args", Value: bson.A{"$$ROOT"}},
+		{Key: "lang", Value: "js"},
+	}}}})
+	return bson.D{{Key: "$project", Value: project}}
+}
+
+func (self *Table) getFastPipeline(where bson.D) (mongo.Pipeline, error) {
+	//先按条件过滤和排序，再排除跳过的字段，最后只返回键和摘要
+	filter, err := self.getChunkFilter(where)
+	if err != nil {
+		return nil, fmt.Errorf("getFastPipeline -> %w", err)
+	}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$sort", Value: self.sort}},
+	}
+	if len(self.projection) > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$project", Value: self.projection}})
+	}
+	return append(pipeline, self.getSumStage()), nil
+}
+
+func (self *Table) checkFastMode() {
+	//fast模式只支持原始文档的crc32，服务端不支持$function时使用slow模式
+	if self.Mode != "fast" {
+		return
+	}
+	if !self.ServerDigest {
+		//默认在本地按BSON计算摘要，可以发现值的类型变化
+		self.Mode = "slow"
+		return
+	}
+	if self.Canonical != nil || self.Hash != "crc32" {
+		slog.Infof("[%s.%s] fast模式只支持--hash=crc32和--doc-hash=raw，使用slow模式", self.DbName, self.TbName)
+		self.Mode = "slow"
+		return
+	}
+
+	pipeline := mongo.Pipeline{{{Key: "$limit", Value: 1}}, self.getSumStage()}
+	for _, conn := range []*mongo.Collection{
+		self.DbGroup.SourceDbConn.Tb(self.DbGroup.SourceDb, self.TbName),
+		self.DbGroup.TargetDbConn.Tb(self.DbGroup.TargetDb, self.TargetTbName),
+	} {
+		cur, err := conn.Aggregate(context.TODO(), pipeline)
+		if err == nil {
+			err = cur.All(context.TODO(), &[]bson.Raw{})
+		}
+		if err != nil {
+			slog.Warnf("[%s.%s] 数据库不支持在服务端计算摘要，使用slow模式：%s", self.DbName, self.TbName, err)
+			self.Mode = "slow"
+			return
+		}
+	}
+}
+
+func (self *Table) pullDataSumFast(coll *mongo.Collection, where bson.D, dataCh chan<- *model.Data, doneCh <-chan struct{}, rows *int) error {
+	//在数据库侧计算摘要，只传输键和摘要
+	pipeline, err := self.getFastPipeline(where)
+	if err != nil {
+		return fmt.Errorf("pullDataSumFast -> %w", err)
+	}
+	cur, err := coll.Aggregate(context.TODO(), pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return fmt.Errorf("pullDataSumFast:Aggregate -> %w", err)
+	}
+	defer cur.Close(context.TODO())
+
+	for cur.Next(context.TODO()) {
+		sum, ok := cur.Current.Lookup(fastSumField).StringValueOK()
+		if !ok {
+			return fmt.Errorf("pullDataSumFast:摘要字段%s无效", fastSumField)
+		}
+		data := model.Data{Id: self.getKey(cur.Current), Sum: sum}
+		select {
+		case dataCh <- &data:
+			*rows++
+		case <-doneCh:
+			slog.Infof("收到停止信号，结束数据下载[%s.%s]", coll.Database().Name(), self.TbName)
+			return nil
+		}
+	}
+	if err = cur.Err(); err != nil {
+		return fmt.Errorf("pullDataSumFast -> %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package mongo
+
+import (
+	"checkData/model"
+	"checkData/util"
+	"context"
+	"encoding/json"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"strconv"
+	"strings"
+)
+
+// 需要核对的集合属性
+var collectionOptions = []string{"validator", "validationLevel", "validationAction"}
+
+func getTableDef(conn *util.MongoDB, dbName, tbName string) (*model.TableDef, error) {
+	//mongo没有固定的列，核对集合的validator和索引
+	specs, err := conn.Db(dbName).ListCollectionSpecifications(context.TODO(), bson.D{{Key: "name", Value: tbName}})
+	if err != nil {
+		return nil, fmt.Errorf("getTableDef -> %w", err)
+	}
+	if len(specs) == 0 {
+		return nil, fmt.Errorf("getTableDef:集合%s不存在", tbName)
+	}
+
+	def := &model.TableDef{Options: make(map[string]string)}
+	for _, name := range collectionOptions {
+		if v, err := specs[0].Options.LookupErr(name); err == nil {
+			def.Options[name] = v.String()
+		}
+	}
+
+	indexes, err := conn.Tb(dbName, tbName).Indexes().ListSpecifications(context.TODO())
+	if err != nil {
+		return nil, fmt.Errorf("getTableDef -> %w", err)
+	}
+	for _, spec := range indexes {
+		idx := model.IndexDef{Name: spec.Name, Unique: spec.Unique != nil && *spec.Unique, Primary: spec.Name == "_id_"}
+		elements, err := spec.KeysDocument.Elements()
+		if err != nil {
+			return nil, fmt.Errorf("getTableDef -> %w", err)
+		}
+		for _, e := range elements {
+			idx.Columns = append(idx.Columns, e.Key()+":"+indexKeyText(e.Value()))
+		}
+		def.Indexes = append(def.Indexes, idx)
+	}
+	return def, nil
+}
+
+func indexKeyText(v bson.RawValue) string {
+	//索引的方向或类型，如 1、-1、text、2dsphere
+	switch v.Type {
+	case bsontype.Int32:
+		return strconv.Itoa(int(v.Int32()))
+	case bsontype.Int64:
+		return strconv.FormatInt(v.Int64(), 10)
+	case bsontype.Double:
+		return strconv.FormatFloat(v.Double(), 'f', -1, 64)
+	case bsontype.String:
+		return v.StringValue()
+	default:
+		return v.String()
+	}
+}
+
+func (self *Table) GetTableDef() (source *model.TableDef, target *model.TableDef, err error) {
+	source, err = getTableDef(self.DbGroup.SourceDbConn, self.DbGroup.SourceDb, self.TbName)
+	if err != nil {
+		return nil, nil, fmt.Errorf("GetTableDef:source -> %w", err)
+	}
+	target, err = getTableDef(self.DbGroup.TargetDbConn, self.DbGroup.TargetDb, self.TargetTbName)
+	if err != nil {
+		return nil, nil, fmt.Errorf("GetTableDef:target -> %w", err)
+	}
+	return source, target, nil
+}
+
+func (self *Table) GetAlterDDL(diffs []model.SchemaDiff) string {
+	//生成mongosh脚本，删除索引的语句被注释，需要确认后再执行
+	dbName, _ := json.Marshal(self.DbGroup.TargetDb)
+	tbName, _ := json.Marshal(self.TargetTbName)
+	coll := fmt.Sprintf("db.getSiblingDB(%s).getCollection(%s)", dbName, tbName)
+
+	var buf strings.Builder
+	var collMod []string
+	for _, d := range diffs {
+		switch d.Kind {
+		case "option":
+			value := d.Source
+			if value == "" {
+				//源端没有这个属性
+				if d.Name != "validator" {
+					continue
+				}
+				value = "{}"
+			}
+			collMod = append(collMod, fmt.Sprintf("%s: EJSON.deserialize(%s)", d.Name, value))
+		case "index_missing":
+			keys := make([]string, 0, len(d.Index.Columns))
+			for _, c := range d.Index.Columns {
+				i := strings.LastIndexByte(c, ':')
+				name, _ := json.Marshal(c[:i])
+				value := c[i+1:]
+				if _, err := strconv.ParseFloat(value, 64); err != nil {
+					text, _ := json.Marshal(value)
+					value = string(text)
+				}
+				keys = append(keys, fmt.Sprintf("%s: %s", name, value))
+			}
+			name, _ := json.Marshal(d.Name)
+			buf.WriteString(fmt.Sprintf("%s.createIndex({%s}, {name: %s, unique: %t});\n", coll, strings.Join(keys, ", "), name, d.Index.Unique))
+		case "index_extra":
+			name, _ := json.Marshal(d.Name)
+			buf.WriteString(fmt.Sprintf("// %s.dropIndex(%s);\n", coll, name))
+		}
+	}
+	if len(collMod) > 0 {
+		buf.WriteString(fmt.Sprintf("db.getSiblingDB(%s).runCommand({collMod: %s, %s});\n", dbName, tbName, strings.Join(collMod, ", ")))
+	}
+	return buf.String()
+}
@@ -4,22 +4,39 @@ import (
 	"checkData/model"
 	"checkData/util"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/gookit/slog"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"strings"
 )
 
 type Table struct {
-	DbName      string
-	TbName      string
-	Mode        string //fast,slow,count
-	Keys        []string
-	Columns     []string
-	Where       string
-	SkipColumns []string
-	DbGroup     *Database
-	Result      *model.Result
+	DbName       string
+	TbName       string
+	TargetTbName string //目标端的集合名，没有映射时和TbName相同
+	Mode         string //fast,slow,count
+	Keys         []string
+	Columns      []string
+	Where        string
+	TargetWhere  string //目标端的过滤条件，由Options.TargetFilter确定
+	SkipColumns  []string
+	Hash         string //摘要算法
+	hashFunc     func([]byte) string
+	Canonical    *Canonical //nil表示使用原始的BSON计算摘要
+	ServerDigest bool       //fast模式在服务端计算摘要，需要明确指定
+	filter       bson.D     //where参数解析后的查询条件
+	targetFilter bson.D     //目标端的查询条件
+	sort         bson.D
+	projection   bson.D       //排除跳过的字段
+	Chunk        *model.Chunk //数据块，nil表示核对整个集合
+	DbGroup      *Database
+	Result       *model.Result
+	recheckDocs  map[model.Key][2]bson.Raw //最后一次复核时查询到的两端文档，不存在时为nil
 }
 
 func (self *Table) GetDbName() string {
@@ -34,9 +51,28 @@ func (self *Table) PreCheck() bool {
 	//预检查
 	slog.Infof("[%s.%s] 执行预检查", self.DbName, self.TbName)
 
+	//摘要算法
+	hashFunc, err := util.NewHashFunc(self.Hash)
+	if err != nil {
+		self.Result.Status = -1
+		self.Result.Message = err.Error()
+		slog.Error(err)
+		return false
+	}
+	self.hashFunc = hashFunc
+
+	err = self.initQuery()
+	if err != nil {
+		self.Result.Status = -1
+		self.Result.Message = err.Error()
+		slog.Error(err)
+		return false
+	}
+
 	if self.Mode == "count" {
 		return true
 	}
+	self.checkFastMode()
 
 	//获取列名
 	tb := self.DbGroup.SourceDbConn.Tb(self.DbName, self.TbName)
@@ -59,16 +95,136 @@ func (self *Table) PreCheck() bool {
 
 }
 
+func (self *Table) initQuery() error {
+	//where参数为Extended JSON格式的查询条件，两端的条件可以不同
+	self.filter = bson.D{}
+	if self.Where != "" {
+		err := bson.UnmarshalExtJSON([]byte(self.Where), false, &self.filter)
+		if err != nil {
+			return fmt.Errorf("initQuery:where参数不是有效的Extended JSON -> %w", err)
+		}
+	}
+	self.targetFilter = bson.D{}
+	if self.TargetWhere != "" {
+		err := bson.UnmarshalExtJSON([]byte(self.TargetWhere), false, &self.targetFilter)
+		if err != nil {
+			return fmt.Errorf("initQuery:target-where参数不是有效的Extended JSON -> %w", err)
+		}
+	}
+
+	//默认使用_id核对，keys参数可以指定唯一的业务字段，支持a.b格式的嵌套字段
+	if len(self.Keys) == 0 {
+		self.Keys = []string{"_id"}
+	}
+	self.sort = bson.D{}
+	for _, k := range self.Keys {
+		self.sort = append(self.sort, bson.E{Key: k, Value: 1})
+	}
+
+	//跳过的字段不参与核对，使用业务字段核对时_id也不参与核对
+	self.projection = bson.D{}
+	for _, c := range self.SkipColumns {
+		if util.InSlice(c, self.Keys) {
+			return fmt.Errorf("initQuery:不能跳过核对的键 %s", c)
+		}
+		self.projection = append(self.projection, bson.E{Key: c, Value: 0})
+	}
+	if !util.InSlice("_id", self.Keys) && !util.InSlice("_id", self.SkipColumns) {
+		self.projection = append(self.projection, bson.E{Key: "_id", Value: 0})
+	}
+	if len(self.Keys) > 1 || self.Keys[0] != "_id" || len(self.projection) > 0 {
+		slog.Infof("[%s.%s] 核对的键: %s 跳过的字段: %s", self.DbName, self.TbName, strings.Join(self.Keys, ", "), strings.Join(self.SkipColumns, ", "))
+	}
+	return nil
+}
+
+func (self *Table) getKey(raw bson.Raw) model.Key {
+	//键的值使用Extended JSON格式，字段不存在或为null时保存为NULL
+	var kb model.KeyBuilder
+	for _, k := range self.Keys {
+		v, err := raw.LookupErr(strings.Split(k, ".")...)
+		if err != nil || v.Type == bsontype.Null {
+			kb.Append(nil, true)
+		} else {
+			kb.Append([]byte(v.String()), false)
+		}
+	}
+	return kb.Key()
+}
+
+func (self *Table) getKeyFilter(id model.Key) (bson.D, error) {
+	//键的值 -> {k1: v1, k2: v2}
+	values, err := id.Values()
+	if err != nil || len(values) != len(self.Keys) {
+		return nil, fmt.Errorf("getKeyFilter:无效的键:%s", id)
+	}
+	filter := bson.D{}
+	for i, k := range self.Keys {
+		if values[i] == nil {
+			filter = append(filter, bson.E{Key: k, Value: nil})
+			continue
+		}
+		v, err := parseExtValue(values[i].(string))
+		if err != nil {
+			return nil, fmt.Errorf("getKeyFilter:无效的键:%s", id)
+		}
+		filter = append(filter, bson.E{Key: k, Value: v})
+	}
+	return filter, nil
+}
+
+func (self *Table) docSum(raw bson.Raw) (string, error) {
+	if self.Canonical == nil {
+		return self.hashFunc(raw), nil
+	}
+	buf, err := self.Canonical.Encode(raw)
+	if err != nil {
+		return "", err
+	}
+	return self.hashFunc(buf), nil
+}
+
+func parseExtValue(text string) (any, error) {
+	//Extended JSON格式的值 -> bson的值
+	var doc bson.D
+	err := bson.UnmarshalExtJSON([]byte(fmt.Sprintf(`{"v" : %s}`, text)), false, &doc)
+	if err != nil {
+		return nil, err
+	}
+	if len(doc) != 1 {
+		return nil, fmt.Errorf("parseExtValue:invalid value %s", text)
+	}
+	return doc[0].Value, nil
+}
+
+func (self *Table) withWhere(filter bson.D) bson.D {
+	//合并where参数的条件
+	return andFilter(self.filter, filter)
+}
+
+func andFilter(where bson.D, filter bson.D) bson.D {
+	if len(where) == 0 {
+		return filter
+	}
+	return bson.D{{Key: "$and", Value: bson.A{where, filter}}}
+}
+
 func (self *Table) pullSourceDataSumSlow(dataCh chan<- *model.Data, doneCh <-chan struct{}) error {
 	//获取源端数据
 
 	slog.Infof("[%s.%s] 开始下载source端数据", self.DbGroup.SourceDb, self.TbName)
 	findOptions := options.Find()
-	findOptions.SetSort(bson.D{{"_id", 1}})
-	cur, err := self.DbGroup.SourceDbConn.Tb(self.DbGroup.SourceDb, self.TbName).Find(context.TODO(), bson.M{}, findOptions)
+	findOptions.SetSort(self.sort)
+	findOptions.SetProjection(self.projection)
+	filter, err := self.getChunkFilter(self.filter)
+	if err != nil {
+		return fmt.Errorf("pullSourceDataSumSlow -> %w", err)
+	}
+	cur, err := self.DbGroup.SourceDbConn.Tb(self.DbGroup.SourceDb, self.TbName).Find(context.TODO(), filter, findOptions)
 	if err != nil {
-		slog.Infof("[%s.%s] 下载source端数据报错，%s", self.DbGroup.SourceDb, self.TbName, err)
+		return fmt.Errorf("pullSourceDataSumSlow:Find -> %w", err)
 	}
+	defer cur.Close(context.TODO())
 
 	var raw bson.Raw
 	for cur.Next(context.TODO()) {
@@ -76,7 +232,11 @@ func (self *Table) pullSourceDataSumSlow(dataCh chan<- *model.Data, doneCh <-cha
 		if err != nil {
 			return fmt.Errorf("pullSourceDataSumSlow:Decode -> %w", err)
 		}
-		data := model.Data{raw.Lookup("_id").String(), util.CRC32Bytes(raw)}
+		sum, err := self.docSum(raw)
+		if err != nil {
+			return fmt.Errorf("docSum -> %w", err)
+		}
+		data := model.Data{Id: self.getKey(raw), Sum: sum}
 		select {
 		case dataCh <- &data:
 			self.Result.SourceRows++
@@ -94,11 +254,17 @@ func (self *Table) pullTargetDataSumSlow(dataCh chan<- *model.Data, doneCh <-cha
 
 	slog.Infof("[%s.%s] 开始下载Target端数据", self.DbGroup.TargetDb, self.TbName)
 	findOptions := options.Find()
-	findOptions.SetSort(bson.D{{"_id", 1}})
-	cur, err := self.DbGroup.TargetDbConn.Tb(self.DbGroup.TargetDb, self.TbName).Find(context.TODO(), bson.M{}, findOptions)
+	findOptions.SetSort(self.sort)
+	findOptions.SetProjection(self.projection)
+	filter, err := self.getChunkFilter(self.targetFilter)
+	if err != nil {
+		return fmt.Errorf("pullTargetDataSumSlow -> %w", err)
+	}
+	cur, err := self.DbGroup.TargetDbConn.Tb(self.DbGroup.TargetDb, self.TargetTbName).Find(context.TODO(), filter, findOptions)
 	if err != nil {
-		slog.Infof("[%s.%s] 下载Target端数据报错，%s", self.DbGroup.TargetDb, self.TbName, err)
+		return fmt.Errorf("pullTargetDataSumSlow:Find -> %w", err)
 	}
+	defer cur.Close(context.TODO())
 
 	var raw bson.Raw
 	for cur.Next(context.TODO()) {
@@ -106,7 +272,11 @@ func (self *Table) pullTargetDataSumSlow(dataCh chan<- *model.Data, doneCh <-cha
 		if err != nil {
 			return fmt.Errorf("pullTargetDataSumSlow:Decode -> %w", err)
 		}
-		data := model.Data{raw.Lookup("_id").String(), util.CRC32Bytes(raw)}
+		sum, err := self.docSum(raw)
+		if err != nil {
+			return fmt.Errorf("docSum -> %w", err)
+		}
+		data := model.Data{Id: self.getKey(raw), Sum: sum}
 		select {
 		case dataCh <- &data:
 			self.Result.TargetRows++
@@ -124,7 +294,11 @@ func (self *Table) PullSourceDataSum(dataCh chan<- *model.Data, doneCh <-chan st
 
 	slog.Infof("[%s.%s] 开始下载Source端数据", self.DbGroup.SourceDb, self.TbName)
 	var err error
-	self.pullSourceDataSumSlow(dataCh, doneCh)
+	if self.Mode == "fast" {
+		err = self.pullDataSumFast(self.DbGroup.SourceDbConn.Tb(self.DbGroup.SourceDb, self.TbName), self.filter, dataCh, doneCh, &self.Result.SourceRows)
+	} else {
+		err = self.pullSourceDataSumSlow(dataCh, doneCh)
+	}
 	if err != nil {
 		self.Result.Status = -1
 		self.Result.Message = err.Error()
@@ -139,7 +313,11 @@ func (self *Table) PullTargetDataSum(dataCh chan<- *model.Data, doneCh <-chan st
 
 	slog.Infof("[%s.%s] 开始下载Target端数据", self.DbGroup.TargetDb, self.TbName)
 	var err error
-	self.pullTargetDataSumSlow(dataCh, doneCh)
+	if self.Mode == "fast" {
+		err = self.pullDataSumFast(self.DbGroup.TargetDbConn.Tb(self.DbGroup.TargetDb, self.TargetTbName), self.targetFilter, dataCh, doneCh, &self.Result.TargetRows)
+	} else {
+		err = self.pullTargetDataSumSlow(dataCh, doneCh)
+	}
 	if err != nil {
 		self.Result.Status = -1
 		self.Result.Message = err.Error()
@@ -148,17 +326,104 @@ func (self *Table) PullTargetDataSum(dataCh chan<- *model.Data, doneCh <-chan st
 	}
 }
 
-func (self *Table) Recheck(idTextList []string) (passList []string) {
-	for _, idText := range idTextList {
-		if self.recheckOne(idText) {
-			passList = append(passList, idText)
+func (self *Table) Recheck(ids []model.Key) (passList []model.Key) {
+	//每次复核只保留这一次的文档
+	self.recheckDocs = make(map[model.Key][2]bson.Raw, len(ids))
+	for _, id := range ids {
+		if self.recheckOne(id) {
+			passList = append(passList, id)
 		}
 	}
 	return passList
 }
 
-func (self *Table) GetRepairSQL(string, int) (string, error) {
-	return "", fmt.Errorf("GetRepairSQL:Unsupported")
+func (self *Table) GetRepairFileExt() string {
+	return "js"
+}
+
+func (self *Table) GetRepairSQL(id model.Key, mode int) (string, error) {
+	// 生成修复数据的mongosh脚本，文档使用canonical Extended JSON，通过EJSON.deserialize还原类型
+	// mode:修复模式, -1:deleteOne, 0:replaceOne  1:insertOne
+	if !util.InSlice(mode, []int{-1, 0, 1}) {
+		return "", fmt.Errorf("GetRepairSQL:Invalid mode %d", mode)
+	}
+
+	filter, err := self.getKeyFilter(id)
+	if err != nil {
+		return "", fmt.Errorf("GetRepairSQL -> %w", err)
+	}
+	filterJson, err := bson.MarshalExtJSON(filter, true, false)
+	if err != nil {
+		return "", fmt.Errorf("GetRepairSQL -> %w", err)
+	}
+
+	dbName, _ := json.Marshal(self.DbGroup.TargetDb)
+	tbName, _ := json.Marshal(self.TargetTbName)
+	coll := fmt.Sprintf("db.getSiblingDB(%s).getCollection(%s)", dbName, tbName)
+	if mode == -1 {
+		return fmt.Sprintf("%s.deleteOne(EJSON.deserialize(%s));\n", coll, filterJson), nil
+	}
+
+	//从源端获取文档
+	raw, err := self.DbGroup.SourceDbConn.Tb(self.DbGroup.SourceDb, self.TbName).FindOne(context.TODO(), filter).DecodeBytes()
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return "", fmt.Errorf("GetRepairSQL:Source端数据不存在 id:[%s]", id)
+	} else if err != nil {
+		return "", fmt.Errorf("GetRepairSQL -> %w", err)
+	}
+
+	//跳过的字段不修改目标端的值，从目标端文档中带过来，目标端多出的其它字段由replaceOne删除
+	var target bson.Raw
+	if mode == 0 && len(self.SkipColumns) > 0 {
+		target, err = self.DbGroup.TargetDbConn.Tb(self.DbGroup.TargetDb, self.TargetTbName).FindOne(context.TODO(), filter).DecodeBytes()
+		if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+			return "", fmt.Errorf("GetRepairSQL -> %w", err)
+		}
+	}
+	doc, err := self.repairDoc(raw, target)
+	if err != nil {
+		return "", fmt.Errorf("GetRepairSQL -> %w", err)
+	}
+	docJson, err := bson.MarshalExtJSON(doc, true, false)
+	if err != nil {
+		return "", fmt.Errorf("GetRepairSQL -> %w", err)
+	}
+
+	if mode == 1 {
+		return fmt.Sprintf("%s.insertOne(EJSON.deserialize(%s));\n", coll, docJson), nil
+	}
+	return fmt.Sprintf("%s.replaceOne(EJSON.deserialize(%s), EJSON.deserialize(%s), {upsert: true});\n", coll, filterJson, docJson), nil
+}
+
+func (self *Table) repairDoc(source, target bson.Raw) (bson.D, error) {
+	//修复使用的文档：目标端文档存在时，源端文档中跳过的字段换成目标端这些字段的值，target为nil时使用源端的完整文档
+	//使用业务字段核对时两端的_id可能不同，不修改目标端的_id
+	var doc bson.D
+	if err := bson.Unmarshal(source, &doc); err != nil {
+		return nil, err
+	}
+	keepId := util.InSlice("_id", self.Keys)
+	fields := bson.D{}
+	for _, e := range doc {
+		if (e.Key == "_id" && !keepId) || (target != nil && util.InSlice(e.Key, self.SkipColumns)) {
+			continue
+		}
+		fields = append(fields, e)
+	}
+	if target == nil {
+		return fields, nil
+	}
+
+	var tdoc bson.D
+	if err := bson.Unmarshal(target, &tdoc); err != nil {
+		return nil, err
+	}
+	for _, e := range tdoc {
+		if e.Key != "_id" && util.InSlice(e.Key, self.SkipColumns) {
+			fields = append(fields, e)
+		}
+	}
+	return fields, nil
 }
 
 func (self *Table) GetSourceTableCount() {
@@ -166,7 +431,7 @@ func (self *Table) GetSourceTableCount() {
 	defer util.TimeCost()(fmt.Sprintf("[%s.%s] Source端总行数统计完成", self.DbGroup.SourceDb, self.TbName))
 
 	slog.Infof("[%s.%s] 开始计算Source端总行数", self.DbGroup.SourceDb, self.TbName)
-	cnt, err := self.DbGroup.SourceDbConn.Tb(self.DbGroup.SourceDb, self.TbName).CountDocuments(context.TODO(), bson.M{})
+	cnt, err := self.DbGroup.SourceDbConn.Tb(self.DbGroup.SourceDb, self.TbName).CountDocuments(context.TODO(), self.filter)
 	if err != nil {
 		self.Result.Status = -1
 		self.Result.Message = fmt.Errorf("GetSourceTableCount:CountDocuments -> %w", err).Error()
@@ -181,7 +446,7 @@ func (self *Table) GetTargetTableCount() {
 	defer util.TimeCost()(fmt.Sprintf("[%s.%s] Target端总行数统计完成", self.DbGroup.TargetDb, self.TbName))
 
 	slog.Infof("[%s.%s] 开始计算Target端总行数", self.DbGroup.TargetDb, self.TbName)
-	cnt, err := self.DbGroup.TargetDbConn.Tb(self.DbGroup.TargetDb, self.TbName).CountDocuments(context.TODO(), bson.M{})
+	cnt, err := self.DbGroup.TargetDbConn.Tb(self.DbGroup.TargetDb, self.TargetTbName).CountDocuments(context.TODO(), self.targetFilter)
 	if err != nil {
 		self.Result.Status = -1
 		self.Result.Message = fmt.Errorf("GetTargetTableCount:CountDocuments -> %w", err).Error()
@@ -191,18 +456,92 @@ func (self *Table) GetTargetTableCount() {
 	self.Result.TargetRows = int(cnt)
 }
 
-func (self *Table) recheckOne(idText string) bool {
+func (self *Table) GetRows(id model.Key) (columns []string, source map[string]string, target map[string]string, err error) {
+	//html报告中展示两端的文档，每个字段的值转成扩展json，列的顺序为源端文档的字段顺序，再加上目标端多出的字段
+	keyFilter, err := self.getKeyFilter(id)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("GetRows -> %w", err)
+	}
+	findOptions := options.FindOne().SetProjection(self.projection)
+	tb1 := self.DbGroup.SourceDbConn.Tb(self.DbGroup.SourceDb, self.TbName)
+	tb2 := self.DbGroup.TargetDbConn.Tb(self.DbGroup.TargetDb, self.TargetTbName)
+	raw1, err := tb1.FindOne(context.TODO(), self.withWhere(keyFilter), findOptions).DecodeBytes()
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil, nil, fmt.Errorf("GetRows:Source端 -> %w", err)
+	}
+	raw2, err := tb2.FindOne(context.TODO(), andFilter(self.targetFilter, keyFilter), findOptions).DecodeBytes()
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil, nil, fmt.Errorf("GetRows:Target端 -> %w", err)
+	}
+	columns, source, target, err = docRows(raw1, raw2)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("GetRows -> %w", err)
+	}
+	return columns, source, target, nil
+}
+
+func docRows(raw1, raw2 bson.Raw) (columns []string, source map[string]string, target map[string]string, err error) {
+	//两端的文档转成每个字段的扩展json，文档不存在时为nil
+	seen := make(map[string]bool)
+	toMap := func(raw bson.Raw) (map[string]string, error) {
+		if raw == nil {
+			return nil, nil
+		}
+		elems, err := raw.Elements()
+		if err != nil {
+			return nil, err
+		}
+		m := make(map[string]string, len(elems))
+		for _, e := range elems {
+			m[e.Key()] = e.Value().String()
+			if !seen[e.Key()] {
+				seen[e.Key()] = true
+				columns = append(columns, e.Key())
+			}
+		}
+		return m, nil
+	}
+	if source, err = toMap(raw1); err != nil {
+		return nil, nil, nil, fmt.Errorf("docRows:Source端 -> %w", err)
+	}
+	if target, err = toMap(raw2); err != nil {
+		return nil, nil, nil, fmt.Errorf("docRows:Target端 -> %w", err)
+	}
+	return columns, source, target, nil
+}
+
+func (self *Table) GetRecheckRows(id model.Key) (columns []string, source map[string]string, target map[string]string, ok bool) {
+	docs, ok := self.recheckDocs[id]
+	if !ok {
+		return nil, nil, nil, false
+	}
+	columns, source, target, err := docRows(docs[0], docs[1])
+	if err != nil {
+		return nil, nil, nil, false
+	}
+	return columns, source, target, true
+}
+
+func (self *Table) recheckOne(id model.Key) bool {
 	//复核一行数据,相同返回true
 
 	//生成filter
-	filterStr := fmt.Sprintf(`{"_id" : %s}`, idText)
-	var filter interface{}
-	bson.UnmarshalExtJSON([]byte(filterStr), false, &filter)
+	keyFilter, err := self.getKeyFilter(id)
+	if err != nil {
+		slog.Errorf("[%s.%s] 复核失败，%s", self.DbGroup.SourceDb, self.TbName, err)
+		return false
+	}
+	filterStr := id.String()
+	findOptions := options.FindOne().SetProjection(self.projection)
 	tb1 := self.DbGroup.SourceDbConn.Tb(self.DbGroup.SourceDb, self.TbName)
-	tb2 := self.DbGroup.TargetDbConn.Tb(self.DbGroup.TargetDb, self.TbName)
+	tb2 := self.DbGroup.TargetDbConn.Tb(self.DbGroup.TargetDb, self.TargetTbName)
 	//核对数据
-	raw1, err1 := tb1.FindOne(context.TODO(), filter).DecodeBytes()
-	raw2, err2 := tb2.FindOne(context.TODO(), filter).DecodeBytes()
+	raw1, err1 := tb1.FindOne(context.TODO(), self.withWhere(keyFilter), findOptions).DecodeBytes()
+	raw2, err2 := tb2.FindOne(context.TODO(), andFilter(self.targetFilter, keyFilter), findOptions).DecodeBytes()
+	if (err1 == nil || errors.Is(err1, mongo.ErrNoDocuments)) && (err2 == nil || errors.Is(err2, mongo.ErrNoDocuments)) {
+		//复核不通过时diff.jsonl直接使用这次查询的文档，不需要再次查询
+		self.recheckDocs[id] = [2]bson.Raw{raw1, raw2}
+	}
 	if err1 != nil && err2 != nil {
 		if err1.Error() == "mongo: no documents in result" && err2.Error() == "mongo: no documents in result" {
 			slog.Infof("[%s.%s] %s 两端都没有此数据,复核通过", self.DbGroup.SourceDb, self.TbName, filterStr)
@@ -223,8 +562,12 @@ func (self *Table) recheckOne(idText string) bool {
 		return false
 	}
 
-	sum1 := util.CRC32Bytes(raw1)
-	sum2 := util.CRC32Bytes(raw2)
+	sum1, err1 := self.docSum(raw1)
+	sum2, err2 := self.docSum(raw2)
+	if err1 != nil || err2 != nil {
+		slog.Errorf("[%s.%s] %s 复核计算摘要报错：%v %v", self.DbGroup.SourceDb, self.TbName, filterStr, err1, err2)
+		return false
+	}
 
 	if sum1 == sum2 {
 		slog.Infof("[%s.%s] %s 两端数据一致,复核通过", self.DbGroup.SourceDb, self.TbName, filterStr)
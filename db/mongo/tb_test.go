@@ -0,0 +1,149 @@
+package mongo
+
+import (
+	"checkData/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"reflect"
+	"testing"
+)
+
+func TestKeyFilter(t *testing.T) {
+	tb := &Table{Keys: []string{"org", "user.no"}, SkipColumns: []string{"updatedAt"}, Result: &model.Result{}}
+	if err := tb.initQuery(); err != nil {
+		t.Fatal(err)
+	}
+	wantProjection := bson.D{{Key: "updatedAt", Value: 0}, {Key: "_id", Value: 0}}
+	if !reflect.DeepEqual(tb.projection, wantProjection) {
+		t.Fatalf("projection = %v, want %v", tb.projection, wantProjection)
+	}
+
+	raw, _ := bson.Marshal(bson.D{
+		{Key: "_id", Value: primitive.NewObjectID()},
+		{Key: "org", Value: "a,b"},
+		{Key: "user", Value: bson.D{{Key: "no", Value: int64(7)}}},
+	})
+	key := tb.getKey(raw)
+	filter, err := tb.getKeyFilter(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := bson.D{{Key: "org", Value: "a,b"}, {Key: "user.no", Value: int64(7)}}
+	if !reflect.DeepEqual(filter, want) {
+		t.Fatalf("getKeyFilter(%s) = %v, want %v", key, filter, want)
+	}
+
+	//字段不存在时为NULL
+	raw, _ = bson.Marshal(bson.D{{Key: "org", Value: "x"}})
+	filter, err = tb.getKeyFilter(tb.getKey(raw))
+	if err != nil || filter[1].Value != nil {
+		t.Fatalf("unexpected filter %v %v", filter, err)
+	}
+
+	tb = &Table{Where: `{"status": {"$in": [1, 2]}}`, SkipColumns: []string{"_id"}, Result: &model.Result{}}
+	if err := tb.initQuery(); err == nil {
+		t.Fatal("expected error when skipping the key")
+	}
+	tb = &Table{Where: `{"status": `, Result: &model.Result{}}
+	if err := tb.initQuery(); err == nil {
+		t.Fatal("expected error for an invalid where")
+	}
+}
+
+func TestChunkFilter(t *testing.T) {
+	tb := &Table{Keys: []string{"_id"}, Where: `{"status": 1}`, Result: &model.Result{}}
+	if err := tb.initQuery(); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := tb.getChunkFilter(tb.filter); err != nil || !reflect.DeepEqual(got, tb.filter) {
+		t.Fatalf("getChunkFilter() = %v, %v, want %v", got, err, tb.filter)
+	}
+
+	id := primitive.NewObjectID()
+	raw, _ := bson.Marshal(bson.D{{Key: "_id", Value: id}})
+	lower := bson.Raw(raw).Lookup("_id").String()
+	tb.Chunk = &model.Chunk{Lower: &lower}
+	want := bson.D{{Key: "$and", Value: bson.A{tb.filter, bson.D{{Key: "_id", Value: bson.D{{Key: "$gte", Value: id}}}}}}}
+	if got, err := tb.getChunkFilter(tb.filter); err != nil || !reflect.DeepEqual(got, want) {
+		t.Fatalf("getChunkFilter() = %v, %v, want %v", got, err, want)
+	}
+
+	//边界值无效时报错，不能变成 $gte: null
+	invalid := `{"$oid": "x"}`
+	tb.Chunk = &model.Chunk{Lower: &invalid}
+	if _, err := tb.getChunkFilter(tb.filter); err == nil {
+		t.Fatal("expected an error for an invalid boundary")
+	}
+}
+
+func TestFastPipeline(t *testing.T) {
+	tb := &Table{Keys: []string{"user.no"}, SkipColumns: []string{"updatedAt"}, Result: &model.Result{}}
+	if err := tb.initQuery(); err != nil {
+		t.Fatal(err)
+	}
+	pipeline, err := tb.getFastPipeline(tb.filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stages []string
+	for _, stage := range pipeline {
+		stages = append(stages, stage[0].Key)
+	}
+	if want := []string{"$match", "$sort", "$project", "$project"}; !reflect.DeepEqual(stages, want) {
+		t.Fatalf("stages = %v, want %v", stages, want)
+	}
+
+	//只返回键和摘要，_id不是键时排除
+	project := pipeline[3][0].Value.(bson.D)
+	var fields []string
+	for _, e := range project {
+		fields = append(fields, e.Key)
+	}
+	if want := []string{"user.no", "_id", fastSumField}; !reflect.DeepEqual(fields, want) {
+		t.Fatalf("fields = %v, want %v", fields, want)
+	}
+}
+
+func TestRepairDoc(t *testing.T) {
+	tb := &Table{Keys: []string{"no"}, SkipColumns: []string{"updatedAt"}}
+	source, _ := bson.Marshal(bson.D{
+		{Key: "_id", Value: int32(1)},
+		{Key: "no", Value: int32(7)},
+		{Key: "name", Value: "a"},
+		{Key: "updatedAt", Value: "s"},
+	})
+	//目标端多出extra字段，跳过的字段值不同
+	target, _ := bson.Marshal(bson.D{
+		{Key: "_id", Value: int32(2)},
+		{Key: "no", Value: int32(7)},
+		{Key: "name", Value: "b"},
+		{Key: "extra", Value: true},
+		{Key: "updatedAt", Value: "t"},
+	})
+
+	doc, err := tb.repairDoc(source, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//replaceOne保留目标端的_id，其它字段替换成修复文档
+	applied := append(bson.D{{Key: "_id", Value: int32(2)}}, doc...)
+	want := bson.D{
+		{Key: "_id", Value: int32(2)},
+		{Key: "no", Value: int32(7)},
+		{Key: "name", Value: "a"},
+		{Key: "updatedAt", Value: "t"},
+	}
+	if !reflect.DeepEqual(applied, want) {
+		t.Fatalf("applied = %v, want %v", applied, want)
+	}
+
+	//目标端不存在时使用源端的完整文档
+	doc, err = tb.repairDoc(source, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = bson.D{{Key: "no", Value: int32(7)}, {Key: "name", Value: "a"}, {Key: "updatedAt", Value: "s"}}
+	if !reflect.DeepEqual(doc, want) {
+		t.Fatalf("repairDoc = %v, want %v", doc, want)
+	}
+}
@@ -0,0 +1,167 @@
+package mssql
+
+import (
+	"checkData/model"
+	"checkData/util"
+	"database/sql"
+	"fmt"
+	"github.com/gookit/slog"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+func (self *Table) getWhere() string {
+	return self.joinWhere(self.Where, self.Keys)
+}
+
+func (self *Table) getTargetWhere() string {
+	return self.joinWhere(self.TargetWhere, self.TargetKeys)
+}
+
+func (self *Table) joinWhere(where string, keys []string) string {
+	//合并where参数和数据块的范围条件，keys是这一端的主键列名
+	var list []string
+	if where != "" {
+		list = append(list, "("+where+")")
+	}
+	if self.Chunk != nil {
+		col := util.EncloseStr(keys[0], quote)
+		if self.Chunk.Lower != nil {
+			list = append(list, fmt.Sprintf("%s >= %s", col, util.EncloseValue(*self.Chunk.Lower, self.escapeValue)))
+		}
+		if self.Chunk.Upper != nil {
+			list = append(list, fmt.Sprintf("%s < %s", col, util.EncloseValue(*self.Chunk.Upper, self.escapeValue)))
+		}
+	}
+	return strings.Join(list, " and ")
+}
+
+func (self *Table) GetChunks(size int) ([]*model.Chunk, error) {
+	//按第一个主键列拆分数据块，每块大约size行，第一个主键列的值重复较多时，数据块会偏大
+	defer util.TimeCost()(fmt.Sprintf("[%s.%s] 拆分数据块完成", self.DbName, self.TbName))
+
+	//没有主键时第一列可能有NULL，按范围拆分会漏掉这些行
+	if self.Keyless {
+		slog.Infof("[%s.%s] 没有主键，不拆分数据块", self.DbName, self.TbName)
+		return []*model.Chunk{{Index: 0}}, nil
+	}
+
+	var chunks []*model.Chunk
+	var lower *string
+	for {
+		where := self.getWhere()
+		if lower != nil {
+			cond := fmt.Sprintf("%s > %s", util.EncloseStr(self.Keys[0], quote), util.EncloseValue(*lower, self.escapeValue))
+			if where == "" {
+				where = cond
+			} else {
+				where += " and " + cond
+			}
+		}
+
+		rows, err := util.QueryReturnListWithNil(self.DbGroup.SourceDbConn, self.getBoundarySQL(where, size-1))
+		if err != nil {
+			return nil, fmt.Errorf("GetChunks -> %w", err)
+		}
+		if len(rows) == 0 || rows[0][0] == nil {
+			break
+		}
+
+		upper := rows[0][0].(string)
+		chunks = append(chunks, &model.Chunk{Index: len(chunks), Lower: lower, Upper: &upper})
+		lower = &upper
+	}
+	chunks = append(chunks, &model.Chunk{Index: len(chunks), Lower: lower})
+
+	slog.Infof("[%s.%s] 按 %s 拆分为 %d 个数据块", self.DbName, self.TbName, self.Keys[0], len(chunks))
+	return chunks, nil
+}
+
+func (self *Table) NewChunkTable(chunk *model.Chunk) model.Table {
+	//复制预检查后的表对象，只核对数据块范围内的数据
+	tb := *self
+	tb.Chunk = chunk
+	tb.Result = &model.Result{DbName: self.DbName, TbName: self.TbName, RecheckPassRows: -1}
+	err := tb.getCheckSQL()
+	if err != nil {
+		tb.Result.Status = -1
+		tb.Result.Message = err.Error()
+	}
+	return &tb
+}
+
+func (self *Table) chunkWhere(chunk *model.Chunk) (string, string) {
+	tb := *self
+	tb.Chunk = chunk
+	return tb.getWhere(), tb.getTargetWhere()
+}
+
+func (self *Table) GetChunkSum(chunk *model.Chunk) (source model.ChunkSum, target model.ChunkSum, err error) {
+	//两端同时计算数据块的聚合校验和
+	sourceWhere, targetWhere := self.chunkWhere(chunk)
+	sourceSQL := self.getChunkSumSQL(self.EnclosedTbName, self.Keys, self.Columns, self.sourceRules, sourceWhere)
+	targetSQL := self.getChunkSumSQL(self.EnclosedTargetTbName, self.TargetKeys, self.TargetColumns, self.targetRules, targetWhere)
+
+	var wg sync.WaitGroup
+	var sourceErr, targetErr error
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		source, sourceErr = queryChunkSum(self.DbGroup.SourceDbConn, sourceSQL)
+	}()
+	go func() {
+		defer wg.Done()
+		target, targetErr = queryChunkSum(self.DbGroup.TargetDbConn, targetSQL)
+	}()
+	wg.Wait()
+
+	if sourceErr != nil {
+		return source, target, fmt.Errorf("GetChunkSum:source -> %w", sourceErr)
+	}
+	if targetErr != nil {
+		return source, target, fmt.Errorf("GetChunkSum:target -> %w", targetErr)
+	}
+	return source, target, nil
+}
+
+func queryChunkSum(db *sql.DB, sqlText string) (model.ChunkSum, error) {
+	var sum model.ChunkSum
+	rows, err := util.QueryReturnList(db, sqlText)
+	if err != nil {
+		return sum, err
+	}
+	if len(rows) == 0 || len(rows[0]) < 2 {
+		return sum, fmt.Errorf("queryChunkSum:empty result")
+	}
+	sum.Rows, err = strconv.Atoi(rows[0][0])
+	if err != nil {
+		return sum, err
+	}
+	sum.Sum = rows[0][1]
+	return sum, nil
+}
+
+func (self *Table) SplitChunk(chunk *model.Chunk, rows int) ([]*model.Chunk, error) {
+	//按源端数据块的中间值一分为二，中间值等于下边界(第一个主键列的值重复)时无法拆分，返回nil
+	if rows < 2 || self.Keyless {
+		return nil, nil
+	}
+	where, _ := self.chunkWhere(chunk)
+	result, err := util.QueryReturnListWithNil(self.DbGroup.SourceDbConn, self.getBoundarySQL(where, rows/2))
+	if err != nil {
+		return nil, fmt.Errorf("SplitChunk -> %w", err)
+	}
+	if len(result) == 0 || result[0][0] == nil {
+		return nil, nil
+	}
+
+	mid := result[0][0].(string)
+	if chunk.Lower != nil && *chunk.Lower == mid {
+		return nil, nil
+	}
+	return []*model.Chunk{
+		{Index: chunk.Index, Lower: chunk.Lower, Upper: &mid},
+		{Index: chunk.Index, Lower: &mid, Upper: chunk.Upper},
+	}, nil
+}
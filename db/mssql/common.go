@@ -12,22 +12,44 @@ import (
 
 func (self *Table) PreCheck() bool {
 	//预检查
-	defer func() { slog.Infof("[%s.%s] SQLText: %s", self.DbName, self.TbName, self.SQLText) }()
+	defer func() {
+		slog.Infof("[%s.%s] SQLText: %s", self.DbName, self.TbName, self.SQLText)
+		if self.TargetSQLText != self.SQLText {
+			slog.Infof("[%s.%s] Target SQLText: %s", self.DbName, self.TbName, self.TargetSQLText)
+		}
+	}()
 
 	slog.Infof("[%s.%s] 执行预检查", self.DbName, self.TbName)
+	if self.TargetTbName != self.TbName {
+		slog.Infof("[%s.%s] 目标端表名: %s", self.DbName, self.TbName, self.TargetTbName)
+	}
 
 	self.getEnclosedTbName()
 
+	//摘要算法
+	hashFunc, err := util.NewHashFunc(self.Hash)
+	if err != nil {
+		self.Result.Status = -1
+		self.Result.Message = err.Error()
+		slog.Error(err)
+		return false
+	}
+	self.hashFunc = hashFunc
+
 	if self.Mode == "count" {
 		self.SQLText = fmt.Sprintf("select count(*) cnt from %s", self.EnclosedTbName)
-		if self.Where != "" {
-			self.SQLText += " where " + self.Where
+		self.TargetSQLText = fmt.Sprintf("select count(*) cnt from %s", self.EnclosedTargetTbName)
+		if where := self.getWhere(); where != "" {
+			self.SQLText += " where " + where
+		}
+		if where := self.getTargetWhere(); where != "" {
+			self.TargetSQLText += " where " + where
 		}
 		return true
 	}
 
 	//获取主键
-	err := self.getKeys()
+	err = self.getKeys()
 	if err != nil {
 		self.Result.Status = -1
 		self.Result.Message = err.Error()
@@ -62,6 +84,13 @@ func (self *Table) PreCheck() bool {
 		slog.Infof("[%s.%s] 跳过不需要核对的列: %s", self.DbName, self.TbName, strings.Join(skipCols, ", "))
 	}
 
+	if len(self.Keys) == 0 {
+		//没有主键和非空唯一索引时，所有列都作为键
+		self.Keyless = true
+		self.Keys, self.Columns = self.Columns, nil
+		slog.Warnf("[%s.%s] 没有主键和非空唯一索引，按整行数据对比", self.DbName, self.TbName)
+	}
+
 	if len(self.Keys) == 0 {
 		self.Result.Status = -1
 		self.Result.Message = "Keys is empty"
@@ -69,7 +98,7 @@ func (self *Table) PreCheck() bool {
 		return false
 	}
 
-	if len(self.Columns) == 0 {
+	if len(self.Columns) == 0 && !self.Keyless {
 		self.Result.Status = -1
 		self.Result.Message = "Columns is empty"
 		slog.Error(fmt.Errorf("Columns is empty"))
@@ -78,6 +107,11 @@ func (self *Table) PreCheck() bool {
 
 	self.KeysText = util.EncloseAndJoin(self.Keys, quote)
 	self.ColumnsText = util.EncloseAndJoin(self.Columns, quote)
+	self.TargetKeys = model.MapNames(self.Keys, self.ColumnMap)
+	self.TargetColumns = model.MapNames(self.Columns, self.ColumnMap)
+	self.TargetKeysText = util.EncloseAndJoin(self.TargetKeys, quote)
+	self.TargetColumnsText = util.EncloseAndJoin(self.TargetColumns, quote)
+	self.initRules()
 
 	err = self.getCheckSQL()
 	if err != nil {
@@ -90,7 +124,7 @@ func (self *Table) PreCheck() bool {
 }
 
 func (self *Table) pullSourceDataSumFast(dataCh chan<- *model.Data, doneCh <-chan struct{}) error {
-	//获取源端数据，在数据库侧计算CRC32，性能高
+	//获取源端数据，在数据库侧计算摘要，性能高
 
 	cur, err := self.DbGroup.SourceDbConn.Query(self.SQLText)
 	if err != nil {
@@ -98,12 +132,32 @@ func (self *Table) pullSourceDataSumFast(dataCh chan<- *model.Data, doneCh <-cha
 	}
 	defer cur.Close() //当连接中断，这个操作会卡住60s+
 
+	//主键列+摘要列
+	values := make([]*sql.RawBytes, len(self.Keys)+1)
+	valuesP := make([]interface{}, len(values))
+	for i := range values {
+		valuesP[i] = &values[i]
+	}
+
+	var kb model.KeyBuilder
 	for cur.Next() {
-		data := model.Data{}
-		err := cur.Scan(&data.Id, &data.Sum)
+		err := cur.Scan(valuesP...)
 		if err != nil {
 			return fmt.Errorf("GetSourceCRC32Data:Scan -> %w", err)
 		}
+
+		kb.Reset()
+		for i := 0; i < len(self.Keys); i++ {
+			if values[i] == nil {
+				kb.Append(nil, true)
+			} else {
+				kb.Append(*values[i], false)
+			}
+		}
+		data := model.Data{Id: kb.Key()}
+		if sum := values[len(self.Keys)]; sum != nil {
+			data.Sum = string(*sum)
+		}
 		select {
 		case dataCh <- &data:
 			self.Result.SourceRows++
@@ -117,19 +171,39 @@ func (self *Table) pullSourceDataSumFast(dataCh chan<- *model.Data, doneCh <-cha
 }
 
 func (self *Table) pullTargetDataSumFast(dataCh chan<- *model.Data, doneCh <-chan struct{}) error {
-	//获取源端数据，在数据库侧计算CRC32，性能高
-	cur, err := self.DbGroup.TargetDbConn.Query(self.SQLText)
+	//获取源端数据，在数据库侧计算摘要，性能高
+	cur, err := self.DbGroup.TargetDbConn.Query(self.TargetSQLText)
 	if err != nil {
 		return fmt.Errorf("GetTargetCRC32Data:Query -> %w", err)
 	}
 	defer cur.Close() //当连接中断，这个操作会卡住60s+
 
+	//主键列+摘要列
+	values := make([]*sql.RawBytes, len(self.Keys)+1)
+	valuesP := make([]interface{}, len(values))
+	for i := range values {
+		valuesP[i] = &values[i]
+	}
+
+	var kb model.KeyBuilder
 	for cur.Next() {
-		data := model.Data{}
-		err := cur.Scan(&data.Id, &data.Sum)
+		err := cur.Scan(valuesP...)
 		if err != nil {
 			return fmt.Errorf("GetTargetCRC32Data:Scan -> %w", err)
 		}
+
+		kb.Reset()
+		for i := 0; i < len(self.Keys); i++ {
+			if values[i] == nil {
+				kb.Append(nil, true)
+			} else {
+				kb.Append(*values[i], false)
+			}
+		}
+		data := model.Data{Id: kb.Key()}
+		if sum := values[len(self.Keys)]; sum != nil {
+			data.Sum = string(*sum)
+		}
 		select {
 		case dataCh <- &data:
 			self.Result.TargetRows++
@@ -143,7 +217,7 @@ func (self *Table) pullTargetDataSumFast(dataCh chan<- *model.Data, doneCh <-cha
 }
 
 func (self *Table) pullSourceDataSumSlow(dataCh chan<- *model.Data, doneCh <-chan struct{}) error {
-	// 获取源端数据，在本地计算CRC32，速度慢
+	// 获取源端数据，在本地计算摘要，速度慢
 
 	cur, err := self.DbGroup.SourceDbConn.Query(self.SQLText)
 	if err != nil {
@@ -162,9 +236,8 @@ func (self *Table) pullSourceDataSumSlow(dataCh chan<- *model.Data, doneCh <-cha
 		valuesP[i] = &values[i]
 	}
 
-	var buf1 strings.Builder
+	var kb model.KeyBuilder
 	var buf2 []byte
-	var sum uint32
 
 	for cur.Next() {
 
@@ -172,34 +245,29 @@ func (self *Table) pullSourceDataSumSlow(dataCh chan<- *model.Data, doneCh <-cha
 			return err
 		}
 
-		buf1.Reset()
-		buf2 = []byte{}
+		kb.Reset()
+		buf2 = buf2[:0]
 
 		//拼接id
 		for i := 0; i < len(self.Keys); i++ {
-			if i > 0 {
-				buf1.WriteString(",")
-			}
-
 			if values[i] == nil {
-				buf1.WriteString("NULL")
+				kb.Append(nil, true)
 			} else {
-				buf1.Write(*values[i])
+				kb.Append(*values[i], false)
 			}
 		}
 
-		// 拼接数据
+		// 拼接数据，每个值带长度前缀，NULL使用单独的标记
 		for i := len(self.Keys); i < len(values); i++ {
+			rule := self.sourceRules[i-len(self.Keys)]
 			if values[i] == nil {
-				buf2 = append(buf2, []byte("NULL")...)
+				buf2 = rule.AppendValue(buf2, nil, true)
 			} else {
-				buf2 = append(buf2, *values[i]...)
+				buf2 = rule.AppendValue(buf2, *values[i], false)
 			}
-
 		}
 
-		sum = util.CRC32Bytes(buf2)
-		data := model.Data{Id: buf1.String(), Sum: sum}
+		data := model.Data{Id: kb.Key(), Sum: self.hashFunc(buf2)}
 		select {
 		case dataCh <- &data:
 			self.Result.SourceRows++
@@ -214,9 +282,9 @@ func (self *Table) pullSourceDataSumSlow(dataCh chan<- *model.Data, doneCh <-cha
 }
 
 func (self *Table) pullTargetDataSumSlow(dataCh chan<- *model.Data, doneCh <-chan struct{}) error {
-	// 获取源端数据，在本地计算CRC32，速度慢
+	// 获取源端数据，在本地计算摘要，速度慢
 
-	cur, err := self.DbGroup.TargetDbConn.Query(self.SQLText)
+	cur, err := self.DbGroup.TargetDbConn.Query(self.TargetSQLText)
 	if err != nil {
 		return fmt.Errorf("GetTargetCRC32DataSlow:Query-> %w", err)
 	}
@@ -233,7 +301,7 @@ func (self *Table) pullTargetDataSumSlow(dataCh chan<- *model.Data, doneCh <-cha
 		valuesP[i] = &values[i]
 	}
 
-	var buf1 strings.Builder
+	var kb model.KeyBuilder
 	var buf2 []byte
 
 	for cur.Next() {
@@ -242,32 +310,29 @@ func (self *Table) pullTargetDataSumSlow(dataCh chan<- *model.Data, doneCh <-cha
 			return err
 		}
 
-		buf1.Reset()
-		buf2 = []byte{}
+		kb.Reset()
+		buf2 = buf2[:0]
 
 		//拼接id
 		for i := 0; i < len(self.Keys); i++ {
-			if i > 0 {
-				buf1.WriteString(",")
-			}
-
 			if values[i] == nil {
-				buf1.WriteString("NULL")
+				kb.Append(nil, true)
 			} else {
-				buf1.Write(*values[i])
+				kb.Append(*values[i], false)
 			}
 		}
 
-		// 拼接数据
+		// 拼接数据，每个值带长度前缀，NULL使用单独的标记
 		for i := len(self.Keys); i < len(values); i++ {
+			rule := self.targetRules[i-len(self.Keys)]
 			if values[i] == nil {
-				buf2 = append(buf2, []byte("NULL")...)
+				buf2 = rule.AppendValue(buf2, nil, true)
 			} else {
-				buf2 = append(buf2, *values[i]...)
+				buf2 = rule.AppendValue(buf2, *values[i], false)
 			}
 		}
 
-		data := model.Data{Id: buf1.String(), Sum: util.CRC32Bytes(buf2)}
+		data := model.Data{Id: kb.Key(), Sum: self.hashFunc(buf2)}
 		select {
 		case dataCh <- &data:
 			self.Result.TargetRows++
@@ -287,7 +352,9 @@ func (self *Table) PullSourceDataSum(dataCh chan<- *model.Data, doneCh <-chan st
 
 	slog.Infof("[%s.%s] 开始下载Source端数据", self.DbGroup.SourceDb, self.TbName)
 	var err error
-	if self.Mode == "slow" {
+	if self.Keyless {
+		err = self.pullDataKeyless(self.DbGroup.SourceDbConn, self.SQLText, dataCh, doneCh, &self.Result.SourceRows)
+	} else if self.Mode == "slow" {
 		err = self.pullSourceDataSumSlow(dataCh, doneCh)
 	} else {
 		err = self.pullSourceDataSumFast(dataCh, doneCh)
@@ -306,7 +373,9 @@ func (self *Table) PullTargetDataSum(dataCh chan<- *model.Data, doneCh <-chan st
 
 	slog.Infof("[%s.%s] 开始下载Target端数据", self.DbGroup.TargetDb, self.TbName)
 	var err error
-	if self.Mode == "slow" {
+	if self.Keyless {
+		err = self.pullDataKeyless(self.DbGroup.TargetDbConn, self.TargetSQLText, dataCh, doneCh, &self.Result.TargetRows)
+	} else if self.Mode == "slow" {
 		err = self.pullTargetDataSumSlow(dataCh, doneCh)
 	} else {
 		err = self.pullTargetDataSumFast(dataCh, doneCh)
@@ -344,7 +413,7 @@ func (self *Table) GetTargetTableCount() {
 	// 返回表总行数
 	defer util.TimeCost()(fmt.Sprintf("[%s.%s] Target端总行数统计完成", self.DbGroup.TargetDb, self.TbName))
 
-	rows, err := util.QueryReturnList(self.DbGroup.TargetDbConn, self.SQLText)
+	rows, err := util.QueryReturnList(self.DbGroup.TargetDbConn, self.TargetSQLText)
 	if err != nil {
 		self.Result.Status = -1
 		self.Result.Message = fmt.Errorf("GetTargetTableCount -> %w", err).Error()
@@ -362,80 +431,160 @@ func (self *Table) GetTargetTableCount() {
 
 }
 
-func (self *Table) getWhereClause(idText string) (string, error) {
-	//预处理主键列值
-	_ids := strings.Split(idText, ",")
-	var ids []string
-	for i := range _ids {
-		ids = append(ids, util.EncloseStr(_ids[i], "'"))
+func (self *Table) getWhereClause(keys []string, id model.Key) (string, error) {
+	//解析主键列值，keys是这一端的主键列名
+	values, err := id.Values()
+	if err != nil {
+		return "", fmt.Errorf("getWhereClause -> %w", err)
 	}
 
 	//拼接where
-	whereClause, err := util.GenerateClause(self.Keys, ids, quote, "AND")
+	whereClause, err := util.GenerateWhereClause(keys, values, quote, self.escapeValue)
 	if err != nil {
 		return "", fmt.Errorf("getWhereClause -> %w", err)
 	}
 	return whereClause, nil
 }
 
-func (self *Table) recheckOne(idText string) bool {
-	//复核一行数据,相同返回true
-	whereClause, err := self.getWhereClause(idText)
+func (self *Table) queryRows(id model.Key) (srow []map[string]string, trow []map[string]string, err error) {
+	//按主键查询两端的数据，目标端的列使用源端列名作为别名
+	whereClause, err := self.getWhereClause(self.Keys, id)
 	if err != nil {
-		slog.Errorf("[%s.%s] 复核失败，生成whereClause报错：%s", self.DbName, self.TbName, err)
-		return false
+		return nil, nil, fmt.Errorf("queryRows -> %w", err)
+	}
+	targetWhereClause, err := self.getWhereClause(self.TargetKeys, id)
+	if err != nil {
+		return nil, nil, fmt.Errorf("queryRows -> %w", err)
 	}
 
-	//核对数据
 	sql := fmt.Sprintf("select %s from %s where %s", self.ColumnsText, self.EnclosedTbName, whereClause)
-	srow, err := util.QueryReturnDict(self.DbGroup.SourceDbConn, sql)
+	srow, err = util.QueryReturnDict(self.DbGroup.SourceDbConn, sql)
 	if err != nil {
-		slog.Errorf("[%s.%s] 复核不一致的数据，查询Source端报错：%s", self.DbName, self.TbName, err)
-		return false
+		return nil, nil, fmt.Errorf("queryRows:Source端 -> %w", err)
+	}
+	sql = fmt.Sprintf("select %s from %s where %s", self.getTargetColumnsAlias(), self.EnclosedTargetTbName, targetWhereClause)
+	trow, err = util.QueryReturnDict(self.DbGroup.TargetDbConn, sql)
+	if err != nil {
+		return nil, nil, fmt.Errorf("queryRows:Target端 -> %w", err)
 	}
-	trow, err := util.QueryReturnDict(self.DbGroup.TargetDbConn, sql)
+	model.NormalizeRows(srow, self.Columns, self.sourceRules)
+	model.NormalizeRows(trow, self.Columns, self.targetRules)
+	return srow, trow, nil
+}
+
+func (self *Table) GetRows(id model.Key) (columns []string, source map[string]string, target map[string]string, err error) {
+	//html报告中展示两端的数据，没有主键的表id就是整行数据，不需要查询
+	if self.Keyless {
+		return nil, nil, nil, fmt.Errorf("GetRows:没有主键的表不支持")
+	}
+	srow, trow, err := self.queryRows(id)
 	if err != nil {
-		slog.Errorf("[%s.%s] 复核不一致的数据，查询Target端报错：%s", self.DbName, self.TbName, err)
+		return nil, nil, nil, fmt.Errorf("GetRows -> %w", err)
+	}
+	if len(srow) > 0 {
+		source = srow[0]
+	}
+	if len(trow) > 0 {
+		target = trow[0]
+	}
+	return self.Columns, source, target, nil
+}
+
+func (self *Table) recheckOne(id model.Key) bool {
+	//复核一行数据,相同返回true
+	if self.Keyless {
+		return self.recheckKeyless(id)
+	}
+
+	//核对数据
+	srow, trow, err := self.queryRows(id)
+	if err != nil {
+		slog.Errorf("[%s.%s] 复核不一致的数据，查询报错：%s", self.DbName, self.TbName, err)
 		return false
 	}
+	self.saveRecheckRows(id, srow, trow)
 	if len(srow) == 0 && len(trow) == 0 {
-		slog.Infof("[%s.%s] 两端均无此数据,复核通过 id:[%s]", self.DbName, self.TbName, idText)
+		slog.Infof("[%s.%s] 两端均无此数据,复核通过 id:[%s]", self.DbName, self.TbName, id)
 		return true
 	} else if len(srow) == 1 && len(srow) == len(trow) {
 		if res, str := util.MapIsEqual(srow[0], trow[0]); res {
-			slog.Infof("[%s.%s] 数据一致,复核通过 id:[%s]", self.DbName, self.TbName, idText)
+			slog.Infof("[%s.%s] 数据一致,复核通过 id:[%s]", self.DbName, self.TbName, id)
 			return true
 		} else {
-			slog.Infof("[%s.%s] 数据不一致,复核不通过 id:[%s] %s", self.DbName, self.TbName, idText, str)
+			slog.Infof("[%s.%s] 数据不一致,复核不通过 id:[%s] %s", self.DbName, self.TbName, id, str)
 		}
 	} else {
-		slog.Infof("[%s.%s] 两端数据行数不一致，复核不通过 id:[%s] rows:[%d] vs [%d]", self.DbName, self.TbName, idText, len(srow), len(trow))
+		slog.Infof("[%s.%s] 两端数据行数不一致，复核不通过 id:[%s] rows:[%d] vs [%d]", self.DbName, self.TbName, id, len(srow), len(trow))
 	}
 	return false
 }
 
-func (self *Table) Recheck(idTextList []string) (passList []string) {
-	for _, idText := range idTextList {
-		if self.recheckOne(idText) {
-			passList = append(passList, idText)
+func (self *Table) getTargetColumnsAlias() string {
+	// tcol as scol
+	list := make([]string, 0, len(self.Columns))
+	for i, c := range self.Columns {
+		col := util.EncloseStr(c, quote)
+		if t := self.TargetColumns[i]; t != c {
+			col = util.EncloseStr(t, quote) + " as " + col
+		}
+		list = append(list, col)
+	}
+	return strings.Join(list, ", ")
+}
+
+func (self *Table) saveRecheckRows(id model.Key, srow []map[string]string, trow []map[string]string) {
+	//复核不通过时diff.jsonl直接使用这次查询的数据，不需要再次查询
+	var rows [2]map[string]string
+	if len(srow) > 0 {
+		rows[0] = srow[0]
+	}
+	if len(trow) > 0 {
+		rows[1] = trow[0]
+	}
+	self.recheckRows[id] = rows
+}
+
+func (self *Table) GetRecheckRows(id model.Key) (columns []string, source map[string]string, target map[string]string, ok bool) {
+	rows, ok := self.recheckRows[id]
+	if !ok {
+		return nil, nil, nil, false
+	}
+	return self.Columns, rows[0], rows[1], true
+}
+
+func (self *Table) Recheck(ids []model.Key) (passList []model.Key) {
+	//每次复核只保留这一次的数据
+	self.recheckRows = make(map[model.Key][2]map[string]string, len(ids))
+	for _, id := range ids {
+		if self.recheckOne(id) {
+			passList = append(passList, id)
 		}
 	}
 	return passList
 }
 
-func (self *Table) GetRepairSQL(idText string, mode int) (string, error) {
+func (self *Table) GetRepairSQL(id model.Key, mode int) (string, error) {
 	// 生成修复数据的sql
 	// mode:修复模式, -1:delete, 0:update  1:insert
 	if !util.InSlice(mode, []int{-1, 0, 1}) {
 		return "", fmt.Errorf("GetRepairSQL:Invalid mode %d", mode)
 	}
+	if self.Keyless {
+		return self.getKeylessRepairSQL(id, mode)
+	}
 
 	var columns []string
 	columns = append(columns, self.Keys...)
 	columns = append(columns, self.Columns...)
 	columnsText := util.EncloseAndJoin(columns, quote)
+	targetColumnsText := self.TargetKeysText + ", " + self.TargetColumnsText
 
-	whereClause, err := self.getWhereClause(idText)
+	//查询源端使用源端的列名，修复SQL使用目标端的表名和列名
+	whereClause, err := self.getWhereClause(self.Keys, id)
+	if err != nil {
+		return "", fmt.Errorf("GetRepairSQL -> %w", err)
+	}
+	targetWhereClause, err := self.getWhereClause(self.TargetKeys, id)
 	if err != nil {
 		return "", fmt.Errorf("GetRepairSQL -> %w", err)
 	}
@@ -444,7 +593,7 @@ func (self *Table) GetRepairSQL(idText string, mode int) (string, error) {
 	switch mode {
 	case -1:
 		//生成delete SQL
-		sqlText.WriteString(fmt.Sprintf("DELETE FROM %s WHERE %s;\n", self.EnclosedTbName, whereClause))
+		sqlText.WriteString(fmt.Sprintf("DELETE FROM %s WHERE %s;\n", self.EnclosedTargetTbName, targetWhereClause))
 	case 0:
 		//生成update SQL
 		sql := fmt.Sprintf("select %s from %s where %s", util.EncloseAndJoin(self.Columns, quote), self.EnclosedTbName, whereClause)
@@ -452,14 +601,17 @@ func (self *Table) GetRepairSQL(idText string, mode int) (string, error) {
 		if err != nil {
 			return "", err
 		}
+		if len(rows) == 0 {
+			return "", fmt.Errorf("GetRepairSQL:Source端数据不存在 id:[%s]", id)
+		}
 		row := util.EncloseValues(rows[0], self.escapeValue)
 		var setClause string
-		setClause, err = util.GenerateClause(self.Columns, row, quote, ",")
+		setClause, err = util.GenerateClause(self.TargetColumns, row, quote, ",")
 		if err != nil {
 			return "", fmt.Errorf("GetRepairSQL:GenerateClause -> %w", err)
 		}
 
-		sqlText.WriteString(fmt.Sprintf("UPDATE %s SET %s WHERE %s;\n", self.EnclosedTbName, setClause, whereClause))
+		sqlText.WriteString(fmt.Sprintf("UPDATE %s SET %s WHERE %s;\n", self.EnclosedTargetTbName, setClause, targetWhereClause))
 
 	case 1:
 		//生成insert SQL
@@ -471,7 +623,7 @@ func (self *Table) GetRepairSQL(idText string, mode int) (string, error) {
 
 		for _, v := range rows {
 			row := util.EncloseValues(v, self.escapeValue)
-			sqlText.WriteString(fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s);\n", self.EnclosedTbName, columnsText, strings.Join(row, ", ")))
+			sqlText.WriteString(fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s);\n", self.EnclosedTargetTbName, targetColumnsText, strings.Join(row, ", ")))
 		}
 	}
 
@@ -0,0 +1,114 @@
+package mssql
+
+import (
+	"checkData/model"
+	"checkData/util"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+func (self *Table) getTableDef(conn *sql.DB, tbName string) (*model.TableDef, error) {
+	//从sys视图读取表结构，类型带上长度和精度，默认值是约束的定义，如 ((0))
+	schema, tb := splitTableName(tbName)
+	object := fmt.Sprintf("object_id(quotename('%s') + '.' + quotename('%s'))", self.escapeValue(schema), self.escapeValue(tb))
+	sqlText := fmt.Sprintf(`select c.name, t.name + case
+  when t.name in ('varchar', 'char', 'varbinary', 'binary') then '(' + case when c.max_length = -1 then 'max' else cast(c.max_length as varchar(10)) end + ')'
+  when t.name in ('nvarchar', 'nchar') then '(' + case when c.max_length = -1 then 'max' else cast(c.max_length / 2 as varchar(10)) end + ')'
+  when t.name in ('decimal', 'numeric') then '(' + cast(c.precision as varchar(10)) + ',' + cast(c.scale as varchar(10)) + ')'
+  when t.name in ('datetime2', 'time', 'datetimeoffset') then '(' + cast(c.scale as varchar(10)) + ')'
+  else '' end, cast(c.is_nullable as int), object_definition(c.default_object_id)
+from sys.columns c join sys.types t on t.user_type_id = c.user_type_id where c.object_id = %s order by c.column_id`, object)
+	rows, err := util.QueryReturnListWithNil(conn, sqlText)
+	if err != nil {
+		return nil, fmt.Errorf("getTableDef -> %w", err)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("getTableDef:表%s不存在", tbName)
+	}
+	def := &model.TableDef{Columns: model.BuildColumns(rows)}
+
+	sqlText = fmt.Sprintf(`select i.name, cast(i.is_unique as int), cast(i.is_primary_key as int), c.name
+from sys.indexes i join sys.index_columns ic on ic.object_id = i.object_id and ic.index_id = i.index_id
+join sys.columns c on c.object_id = ic.object_id and c.column_id = ic.column_id
+where i.object_id = %s and ic.is_included_column = 0 order by i.name, ic.key_ordinal`, object)
+	list, err := util.QueryReturnList(conn, sqlText)
+	if err != nil {
+		return nil, fmt.Errorf("getTableDef -> %w", err)
+	}
+	def.Indexes = model.BuildIndexes(list)
+	return def, nil
+}
+
+func (self *Table) GetTableDef() (source *model.TableDef, target *model.TableDef, err error) {
+	self.getEnclosedTbName()
+	source, err = self.getTableDef(self.DbGroup.SourceDbConn, self.TbName)
+	if err != nil {
+		return nil, nil, fmt.Errorf("GetTableDef:source -> %w", err)
+	}
+	target, err = self.getTableDef(self.DbGroup.TargetDbConn, self.TargetTbName)
+	if err != nil {
+		return nil, nil, fmt.Errorf("GetTableDef:target -> %w", err)
+	}
+	return source, target, nil
+}
+
+func (self *Table) GetAlterDDL(diffs []model.SchemaDiff) string {
+	//生成使目标端和源端一致的DDL，删除列和索引的语句被注释，需要确认后再执行
+	var buf strings.Builder
+	tb := self.EnclosedTargetTbName
+	modified := make(map[string]bool)
+	for _, d := range diffs {
+		name := util.EncloseStr(d.Name, quote)
+		switch d.Kind {
+		case "column_missing":
+			text := d.Column.Type + nullText(d.Column.Nullable)
+			if d.Column.Default != nil {
+				text += " DEFAULT " + *d.Column.Default
+			}
+			buf.WriteString(fmt.Sprintf("ALTER TABLE %s ADD %s %s;\n", tb, name, text))
+		case "column_type", "column_nullable":
+			//同一列的类型和是否可空使用一条语句修改
+			if modified[d.Name] {
+				continue
+			}
+			modified[d.Name] = true
+			buf.WriteString(fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s %s%s;\n", tb, name, d.Column.Type, nullText(d.Column.Nullable)))
+		case "column_default":
+			//默认值是约束，目标端已有默认值时需要先删除原来的约束
+			switch {
+			case d.Column.Default == nil:
+				buf.WriteString(fmt.Sprintf("-- 删除目标端 %s 列的默认值约束\n", name))
+			case d.Target != "<none>":
+				buf.WriteString(fmt.Sprintf("-- 删除目标端 %s 列的默认值约束后执行: ALTER TABLE %s ADD DEFAULT %s FOR %s;\n", name, tb, *d.Column.Default, name))
+			default:
+				buf.WriteString(fmt.Sprintf("ALTER TABLE %s ADD DEFAULT %s FOR %s;\n", tb, *d.Column.Default, name))
+			}
+		case "column_extra":
+			buf.WriteString(fmt.Sprintf("-- ALTER TABLE %s DROP COLUMN %s;\n", tb, name))
+		case "primary_key":
+			if d.Name != "" {
+				buf.WriteString(fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT %s;\n", tb, name))
+			}
+			if d.Index != nil {
+				buf.WriteString(fmt.Sprintf("ALTER TABLE %s ADD PRIMARY KEY (%s);\n", tb, util.EncloseAndJoin(d.Index.Columns, quote)))
+			}
+		case "index_missing":
+			unique := ""
+			if d.Index.Unique {
+				unique = "UNIQUE "
+			}
+			buf.WriteString(fmt.Sprintf("CREATE %sINDEX %s ON %s (%s);\n", unique, name, tb, util.EncloseAndJoin(d.Index.Columns, quote)))
+		case "index_extra":
+			buf.WriteString(fmt.Sprintf("-- DROP INDEX %s ON %s;\n", name, tb))
+		}
+	}
+	return buf.String()
+}
+
+func nullText(nullable bool) string {
+	if nullable {
+		return " NULL"
+	}
+	return " NOT NULL"
+}
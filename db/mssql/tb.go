@@ -12,19 +12,36 @@ import (
 const quote = `"`
 
 type Table struct {
-	DbName         string
-	TbName         string
-	EnclosedTbName string
-	Mode           string //fast,slow,count
-	Keys           []string
-	Columns        []string
-	Where          string
-	SkipColumns    []string
-	KeysText       string
-	ColumnsText    string
-	SQLText        string
-	DbGroup        *Database
-	Result         *model.Result
+	DbName               string
+	TbName               string
+	EnclosedTbName       string
+	TargetTbName         string //目标端的表名，没有映射时和TbName相同
+	EnclosedTargetTbName string
+	Mode                 string //fast,slow,count
+	Keys                 []string
+	Columns              []string
+	ColumnMap            map[string]string //源端列名 -> 目标端列名
+	TargetKeys           []string
+	TargetColumns        []string
+	Where                string
+	TargetWhere          string //目标端的过滤条件，由Options.TargetFilter确定
+	SkipColumns          []string
+	KeysText             string
+	ColumnsText          string
+	TargetKeysText       string
+	TargetColumnsText    string
+	SQLText              string
+	TargetSQLText        string
+	Hash                 string //摘要算法
+	hashFunc             func([]byte) string
+	Rules                map[string]*model.ColumnRule //源端列名 -> 规范化规则
+	sourceRules          []*model.ColumnRule          //和Columns按位置对应的规范化规则
+	targetRules          []*model.ColumnRule          //目标端的规范化规则，不做时间偏移
+	Keyless              bool                         //没有主键和非空唯一索引，所有列都作为键，按行的多重集合对比
+	Chunk                *model.Chunk                 //数据块，nil表示核对整个表
+	DbGroup              *Database
+	Result               *model.Result
+	recheckRows          map[model.Key][2]map[string]string //最后一次复核时查询到的两端数据，行不存在时为nil
 }
 
 func (self *Table) GetDbName() string {
@@ -35,11 +52,11 @@ func (self *Table) GetTbName() string {
 	return self.TbName
 }
 
-func (self *Table) splitTableName() (string, string) {
+func splitTableName(tbName string) (string, string) {
 	//拆分列名
-	l := strings.Split(self.TbName, `.`)
+	l := strings.Split(tbName, `.`)
 	if len(l) != 2 {
-		fmt.Printf("表名格式错误: %s (正确格式:schema_name.table_name)\n", self.TbName)
+		fmt.Printf("表名格式错误: %s (正确格式:schema_name.table_name)\n", tbName)
 		os.Exit(-1)
 	}
 	schema := l[0]
@@ -49,8 +66,10 @@ func (self *Table) splitTableName() (string, string) {
 
 func (self *Table) getEnclosedTbName() {
 	//self.EnclosedTbName = util.EncloseStr(self.TbName, quote)
-	schema, tb := self.splitTableName()
+	schema, tb := splitTableName(self.TbName)
 	self.EnclosedTbName = util.EncloseStr(schema, quote) + "." + util.EncloseStr(tb, quote)
+	schema, tb = splitTableName(self.TargetTbName)
+	self.EnclosedTargetTbName = util.EncloseStr(schema, quote) + "." + util.EncloseStr(tb, quote)
 }
 
 func (self *Table) getKeys() error {
@@ -58,7 +77,7 @@ func (self *Table) getKeys() error {
 		return nil
 	}
 
-	schema, tb := self.splitTableName()
+	schema, tb := splitTableName(self.TbName)
 
 	sql := fmt.Sprintf(`SELECT COLUMN_NAME FROM INFORMATION_SCHEMA.KEY_COLUMN_USAGE
 where CONSTRAINT_NAME in (
@@ -75,13 +94,41 @@ order by ORDINAL_POSITION`, schema, tb)
 		self.Keys = append(self.Keys, row[0])
 	}
 
+	//没有主键时使用非空的唯一索引
+	if len(self.Keys) == 0 {
+		name, keys, err := self.getUniqueKeys()
+		if err != nil {
+			return fmt.Errorf("getKeys -> %w", err)
+		}
+		if len(keys) > 0 {
+			slog.Infof("[%s.%s] 没有主键，使用唯一索引 %s", self.DbName, self.TbName, name)
+			self.Keys = keys
+		}
+	}
+
 	slog.Infof("[%s.%s] 主键列: %s", self.DbName, self.TbName, strings.Join(self.Keys, ", "))
 	return nil
 }
 
+func (self *Table) getUniqueKeys() (string, []string, error) {
+	//查询唯一索引的列和列是否可空，选择列最少的非空唯一索引
+	schema, tb := splitTableName(self.TbName)
+	sql := fmt.Sprintf(`select i.name, c.name, cast(c.is_nullable as int)
+from sys.indexes i join sys.index_columns ic on ic.object_id = i.object_id and ic.index_id = i.index_id
+join sys.columns c on c.object_id = ic.object_id and c.column_id = ic.column_id
+where i.object_id = object_id(quotename('%s') + '.' + quotename('%s')) and i.is_unique = 1 and i.has_filter = 0 and ic.is_included_column = 0
+order by i.name, ic.key_ordinal`, self.escapeValue(schema), self.escapeValue(tb))
+	rows, err := util.QueryReturnList(self.DbGroup.SourceDbConn, sql)
+	if err != nil {
+		return "", nil, fmt.Errorf("getUniqueKeys -> %w", err)
+	}
+	name, keys := model.PickUniqueKey(rows)
+	return name, keys, nil
+}
+
 func (self *Table) getColumns() error {
 	// 获取列名
-	schema, tb := self.splitTableName()
+	schema, tb := splitTableName(self.TbName)
 	sql := fmt.Sprintf(`select COLUMN_NAME from INFORMATION_SCHEMA.COLUMNS where TABLE_SCHEMA='%s' and TABLE_NAME='%s' order by ORDINAL_POSITION`, schema, tb)
 
 	rows, err := util.QueryReturnList(self.DbGroup.SourceDbConn, sql)
@@ -98,21 +145,92 @@ func (self *Table) getColumns() error {
 
 func (self *Table) getCheckSQL() error {
 
-	var sql string
-	if self.Mode == "slow" {
-		sql = fmt.Sprintf("select %s, %s from %s", self.KeysText, self.ColumnsText, self.EnclosedTbName)
-	} else {
-		sql = fmt.Sprintf("select %s as pk,crc32(concat(%s)) as rowdata from %s", self.KeysText, self.ColumnsText, self.TbName)
+	sql, err := self.getSelectSQL(self.EnclosedTbName, self.TbName, self.KeysText, self.ColumnsText, self.Columns, self.sourceRules)
+	if err != nil {
+		return fmt.Errorf("getCheckSQL -> %w", err)
+	}
+	targetSQL, err := self.getSelectSQL(self.EnclosedTargetTbName, self.TargetTbName, self.TargetKeysText, self.TargetColumnsText, self.TargetColumns, self.targetRules)
+	if err != nil {
+		return fmt.Errorf("getCheckSQL -> %w", err)
 	}
 
-	if self.Where != "" {
-		sql += " where " + self.Where
+	self.SQLText, self.TargetSQLText = sql, targetSQL
+	if where := self.getWhere(); where != "" {
+		self.SQLText += " where " + where
 	}
-	self.SQLText = sql + " order by " + self.KeysText
+	if where := self.getTargetWhere(); where != "" {
+		self.TargetSQLText += " where " + where
+	}
+	self.SQLText += " order by " + self.KeysText
+	self.TargetSQLText += " order by " + self.TargetKeysText
 
 	return nil
 }
 
+func (self *Table) getSelectSQL(tbName, fastTbName, keysText, columnsText string, columns []string, rules []*model.ColumnRule) (string, error) {
+	//生成一端的核对SQL，两端的列顺序相同，列名可能不同
+	if self.Keyless {
+		//整行数据作为键，不需要摘要
+		return fmt.Sprintf("select %s from %s", keysText, tbName), nil
+	}
+	if self.Mode == "slow" {
+		return fmt.Sprintf("select %s, %s from %s", keysText, columnsText, tbName), nil
+	}
+	sumExpr, err := self.getSumExpr(self.getRowExpr(columns, rules))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("select %s,%s as rowdata from %s", keysText, sumExpr, fastTbName), nil
+}
+
+func (self *Table) getRowExpr(columns []string, rules []*model.ColumnRule) string {
+	//拼接数据的表达式，每个值带长度前缀，NULL使用N标记，规则和util.AppendEncodedValue一致，有规范化规则的列先规范化
+	//len()会忽略末尾空格，使用datalength计算长度
+	list := make([]string, 0, len(columns))
+	for i, c := range columns {
+		col := fmt.Sprintf("cast(%s as nvarchar(max))", ruleExpr(util.EncloseStr(c, quote), rules[i]))
+		list = append(list, fmt.Sprintf("coalesce(cast(datalength(%s) as varchar(20))+':'+%s,N'N')", col, col))
+	}
+	return fmt.Sprintf("(%s)", strings.Join(list, "+"))
+}
+
+func (self *Table) getSumExpr(text string) (string, error) {
+	//在数据库侧计算摘要的表达式，crc32需要自行创建函数
+	switch self.Hash {
+	case "", "crc32":
+		return fmt.Sprintf("crc32(%s)", text), nil
+	case "md5":
+		return fmt.Sprintf("lower(convert(varchar(32),hashbytes('MD5',%s),2))", text), nil
+	case "sha256":
+		return fmt.Sprintf("lower(left(convert(varchar(64),hashbytes('SHA2_256',%s),2),32))", text), nil
+	default:
+		return "", fmt.Errorf("getSumExpr:fast模式不支持%s算法，请使用slow模式", self.Hash)
+	}
+}
+
+func (self *Table) getBoundarySQL(where string, offset int) string {
+	//获取数据块的边界值
+	col := util.EncloseStr(self.Keys[0], quote)
+	sql := fmt.Sprintf("select %s from %s", col, self.EnclosedTbName)
+	if where != "" {
+		sql += " where " + where
+	}
+	return fmt.Sprintf("%s order by %s offset %d rows fetch next 1 rows only", sql, col, offset)
+}
+
+func (self *Table) getChunkSumSQL(tbName string, keys []string, columns []string, rules []*model.ColumnRule, where string) string {
+	//数据块的聚合校验和，每行取md5的前56位求和，行数据包含主键列，hashbytes在2016之前的版本输入不能超过8000字节
+	list := make([]string, 0, len(keys)+len(columns))
+	list = append(list, keys...)
+	list = append(list, columns...)
+	rules = append(make([]*model.ColumnRule, len(keys)), rules...)
+	sql := fmt.Sprintf("select count_big(*),isnull(sum(cast(convert(bigint,substring(hashbytes('MD5',%s),1,7)) as decimal(38,0))),0) from %s", self.getRowExpr(list, rules), tbName)
+	if where != "" {
+		sql += " where " + where
+	}
+	return sql
+}
+
 func (self *Table) escapeValue(val string) string {
 	// 此函数用于转义 值中的单引号和反斜杠等，生成修复SQL时需要使用
 	// 值中的 ' -> ''
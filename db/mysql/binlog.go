@@ -0,0 +1,372 @@
+package mysql
+
+import (
+	"bufio"
+	"checkData/model"
+	"checkData/util"
+	"errors"
+	"fmt"
+	driver "github.com/go-sql-driver/mysql"
+	"github.com/gookit/slog"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// BinlogRow mysqlbinlog -vv 输出中一行数据的前镜像(WHERE)或后镜像(SET)
+type BinlogRow struct {
+	Db       string
+	Table    string
+	Values   map[int]*string //列序号(从1开始) -> 值，nil表示NULL
+	Unsigned map[int]string  //负数按无符号解析的值，如 @1=-1 (4294967295)
+}
+
+// BinlogParser 解析 mysqlbinlog --base64-output=DECODE-ROWS -vv 的输出，例如:
+// ### UPDATE `db1`.`t1`
+// ### WHERE
+// ###   @1=1 /* INT meta=0 nullable=0 is_null=0 */
+// ###   @2='a\x27b' /* VARSTRING(80) meta=80 nullable=1 is_null=0 */
+// ### SET
+// ###   @1=1 /* INT meta=0 nullable=0 is_null=0 */
+// ###   @2=NULL /* VARSTRING(80) meta=80 nullable=1 is_null=1 */
+// 字符串中的 ' \ 和控制字符输出为\xNN，TIMESTAMP类型输出为unix时间戳
+type BinlogParser struct {
+	scanner *bufio.Scanner
+	db      string
+	table   string
+	cur     *BinlogRow
+}
+
+func NewBinlogParser(r io.Reader) *BinlogParser {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024*1024)
+	return &BinlogParser{scanner: scanner}
+}
+
+func (self *BinlogParser) Next() (*BinlogRow, error) {
+	//返回下一行数据的镜像，输出结束时返回io.EOF
+	for self.scanner.Scan() {
+		line := self.scanner.Text()
+		if strings.HasPrefix(line, "###   @") {
+			if self.cur != nil {
+				if err := self.cur.parseValue(line[len("###   @"):]); err != nil {
+					return nil, fmt.Errorf("BinlogParser.Next -> %w", err)
+				}
+			}
+			continue
+		}
+
+		row := self.flush()
+		switch {
+		case strings.HasPrefix(line, "### INSERT INTO "):
+			self.db, self.table = parseBinlogTable(line[len("### INSERT INTO "):])
+		case strings.HasPrefix(line, "### UPDATE "):
+			self.db, self.table = parseBinlogTable(line[len("### UPDATE "):])
+		case strings.HasPrefix(line, "### DELETE FROM "):
+			self.db, self.table = parseBinlogTable(line[len("### DELETE FROM "):])
+		case line == "### WHERE" || line == "### SET":
+			self.cur = &BinlogRow{Db: self.db, Table: self.table, Values: make(map[int]*string), Unsigned: make(map[int]string)}
+		}
+		if row != nil {
+			return row, nil
+		}
+	}
+	if err := self.scanner.Err(); err != nil {
+		return nil, fmt.Errorf("BinlogParser.Next -> %w", err)
+	}
+	if row := self.flush(); row != nil {
+		return row, nil
+	}
+	return nil, io.EOF
+}
+
+func (self *BinlogParser) flush() *BinlogRow {
+	row := self.cur
+	self.cur = nil
+	if row == nil || len(row.Values) == 0 {
+		return nil
+	}
+	return row
+}
+
+func parseBinlogTable(text string) (string, string) {
+	// `db1`.`t1` -> db1, t1
+	l := strings.SplitN(text, "`.`", 2)
+	if len(l) != 2 {
+		return "", ""
+	}
+	return strings.TrimPrefix(l[0], "`"), strings.TrimSuffix(strings.TrimSpace(l[1]), "`")
+}
+
+func (self *BinlogRow) parseValue(text string) error {
+	// 1=-1 (4294967295) /* INT meta=0 nullable=0 is_null=0 */
+	i := strings.IndexByte(text, '=')
+	if i < 0 {
+		return fmt.Errorf("parseValue:invalid line %s", text)
+	}
+	pos, err := strconv.Atoi(text[:i])
+	if err != nil {
+		return fmt.Errorf("parseValue:invalid line %s", text)
+	}
+	text = text[i+1:]
+
+	if strings.HasPrefix(text, "'") {
+		end := strings.IndexByte(text[1:], '\'')
+		if end < 0 {
+			return fmt.Errorf("parseValue:invalid string %s", text)
+		}
+		value, err := unescapeBinlogString(text[1 : end+1])
+		if err != nil {
+			return err
+		}
+		self.Values[pos] = &value
+		return nil
+	}
+
+	value, rest, _ := strings.Cut(text, " ")
+	if value == "NULL" {
+		self.Values[pos] = nil
+		return nil
+	}
+	self.Values[pos] = &value
+	if strings.HasPrefix(rest, "(") {
+		if end := strings.IndexByte(rest, ')'); end > 0 {
+			self.Unsigned[pos] = rest[1:end]
+		}
+	}
+	return nil
+}
+
+func unescapeBinlogString(text string) (string, error) {
+	if !strings.Contains(text, `\x`) {
+		return text, nil
+	}
+	var buf strings.Builder
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\\' && i+3 < len(text) && text[i+1] == 'x' {
+			b, err := strconv.ParseUint(text[i+2:i+4], 16, 8)
+			if err != nil {
+				return "", fmt.Errorf("unescapeBinlogString:invalid string %s", text)
+			}
+			buf.WriteByte(byte(b))
+			i += 3
+		} else {
+			buf.WriteByte(text[i])
+		}
+	}
+	return buf.String(), nil
+}
+
+// binlogTable 主键列在binlog中的列序号
+type binlogTable struct {
+	positions []int
+	unsigned  []bool
+	timestamp []bool                       //TIMESTAMP列在binlog中是unix时间戳
+	fromUnix  func(string) (string, error) //把unix时间戳转换成源端会话时区的时间，和复核的查询条件一致
+}
+
+// BinlogSource 调用mysqlbinlog从源端实时读取row格式的binlog，解析出需要核对的表中有变更的主键
+type BinlogSource struct {
+	db     *Database
+	cmd    *exec.Cmd
+	parser *BinlogParser
+	tables map[string]*binlogTable
+}
+
+func NewBinlogSource(db model.Database, opt *model.Options) (*BinlogSource, error) {
+	mdb, ok := db.(*Database)
+	if !ok {
+		return nil, fmt.Errorf("NewBinlogSource:unsupported database %T", db)
+	}
+
+	file, pos := opt.BinlogFile, opt.BinlogPos
+	if file == "" {
+		//从当前位置开始读取
+		rows, err := util.QueryReturnList(mdb.SourceDbConn, "show master status")
+		if err != nil {
+			rows, err = util.QueryReturnList(mdb.SourceDbConn, "show binary log status")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("NewBinlogSource -> %w", err)
+		}
+		if len(rows) == 0 {
+			return nil, fmt.Errorf("NewBinlogSource:源端没有开启binlog")
+		}
+		file = rows[0][0]
+		pos, _ = strconv.Atoi(rows[0][1])
+	}
+	if pos <= 0 {
+		pos = 4
+	}
+
+	//使用连接串时从连接串中取出地址和账号，tls参数不会传给mysqlbinlog
+	host, port, user, password := mdb.SourceHost, strconv.Itoa(mdb.SourcePort), opt.User, opt.Password
+	if opt.SourceDsn != "" {
+		cfg, err := driver.ParseDSN(opt.SourceDsn)
+		if err != nil {
+			return nil, fmt.Errorf("NewBinlogSource -> %w", err)
+		}
+		host, port, _ = strings.Cut(cfg.Addr, ":")
+		user, password = cfg.User, cfg.Passwd
+	}
+
+	cmd := exec.Command(opt.Mysqlbinlog,
+		"--read-from-remote-server",
+		"--host="+host,
+		"--port="+port,
+		"--user="+user,
+		"--base64-output=DECODE-ROWS",
+		"-vv",
+		"--stop-never",
+		"--start-position="+strconv.Itoa(pos),
+		file)
+	cmd.Env = append(os.Environ(), "MYSQL_PWD="+password) //不在命令行中暴露密码
+	cmd.Stderr = os.Stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("NewBinlogSource -> %w", err)
+	}
+	if err = cmd.Start(); err != nil {
+		return nil, fmt.Errorf("NewBinlogSource -> %w", err)
+	}
+	slog.Infof("[%s] 开始读取binlog [%s:%d]", mdb.SourceDb, file, pos)
+
+	return &BinlogSource{
+		db:     mdb,
+		cmd:    cmd,
+		parser: NewBinlogParser(stdout),
+		tables: make(map[string]*binlogTable),
+	}, nil
+}
+
+func (self *BinlogSource) Next() (*model.Change, error) {
+	for {
+		row, err := self.parser.Next()
+		if errors.Is(err, io.EOF) {
+			if err = self.cmd.Wait(); err != nil {
+				return nil, fmt.Errorf("BinlogSource.Next:mysqlbinlog退出 -> %w", err)
+			}
+			return nil, io.EOF
+		} else if err != nil {
+			return nil, err
+		}
+
+		if row.Db != self.db.SourceDb {
+			continue
+		}
+		tb := self.getTable(row.Table)
+		if tb == nil {
+			continue
+		}
+		key, err := tb.key(row)
+		if err != nil {
+			slog.Warnf("[%s.%s] 解析主键报错，忽略这行变更：%s", self.db.SourceDb, row.Table, err)
+			continue
+		}
+		return &model.Change{Table: row.Table, Key: key}, nil
+	}
+}
+
+func (self *BinlogSource) getTable(tbName string) *binlogTable {
+	//只处理需要核对的表，获取主键列的位置，失败的表返回nil
+	if tb, ok := self.tables[tbName]; ok {
+		return tb
+	}
+
+	var tb *binlogTable
+	if util.InSlice(tbName, self.db.Tables.ToCheck) {
+		var err error
+		tb, err = self.getKeyPositions(tbName)
+		if err != nil {
+			slog.Errorf("[%s.%s] 获取主键列报错，忽略这个表的变更：%s", self.db.SourceDb, tbName, err)
+		}
+	}
+	self.tables[tbName] = tb
+	return tb
+}
+
+func (self *BinlogSource) getKeyPositions(tbName string) (*binlogTable, error) {
+	t := self.db.NewTable(tbName).(*Table)
+	t.getEnclosedTbName()
+	if err := t.getKeys(); err != nil {
+		return nil, fmt.Errorf("getKeyPositions -> %w", err)
+	}
+	if len(t.Keys) == 0 {
+		return nil, fmt.Errorf("getKeyPositions:Keys is empty")
+	}
+
+	rows, err := util.QueryReturnList(self.db.SourceDbConn, fmt.Sprintf("desc %s", t.EnclosedTbName))
+	if err != nil {
+		return nil, fmt.Errorf("getKeyPositions -> %w", err)
+	}
+
+	tb := &binlogTable{fromUnix: self.fromUnixTime}
+	for _, k := range t.Keys {
+		pos := -1
+		for i, row := range rows {
+			if row[0] == k {
+				pos = i + 1
+				tb.unsigned = append(tb.unsigned, strings.Contains(row[1], "unsigned"))
+				tb.timestamp = append(tb.timestamp, strings.HasPrefix(strings.ToLower(row[1]), "timestamp"))
+				break
+			}
+		}
+		if pos < 0 {
+			return nil, fmt.Errorf("getKeyPositions:列 %s 不存在", k)
+		}
+		tb.positions = append(tb.positions, pos)
+	}
+	return tb, nil
+}
+
+func (self *BinlogSource) fromUnixTime(value string) (string, error) {
+	//mysqlbinlog输出的TIMESTAMP是unix时间戳，如 1760752800.123，使用源端的连接转换，时区和复核时相同
+	intPart, frac, _ := strings.Cut(value, ".")
+	if intPart == "" || strings.Trim(intPart+frac, "0123456789") != "" {
+		return "", fmt.Errorf("fromUnixTime:invalid timestamp %s", value)
+	}
+	if strings.Trim(value, "0.") == "" {
+		return "0000-00-00 00:00:00", nil
+	}
+	rows, err := util.QueryReturnList(self.db.SourceDbConn, fmt.Sprintf("select from_unixtime(%s)", value))
+	if err != nil {
+		return "", fmt.Errorf("fromUnixTime -> %w", err)
+	}
+	return rows[0][0], nil
+}
+
+func (self *binlogTable) key(row *BinlogRow) (model.Key, error) {
+	var kb model.KeyBuilder
+	for i, pos := range self.positions {
+		value, ok := row.Values[pos]
+		if !ok {
+			return "", fmt.Errorf("key:binlog中缺少主键列 @%d", pos)
+		}
+		if value == nil {
+			kb.Append(nil, true)
+			continue
+		}
+		switch u, ok := row.Unsigned[pos]; {
+		case ok && self.unsigned[i]:
+			kb.Append([]byte(u), false)
+		case self.timestamp[i]:
+			v, err := self.fromUnix(*value)
+			if err != nil {
+				return "", fmt.Errorf("key -> %w", err)
+			}
+			kb.Append([]byte(v), false)
+		default:
+			kb.Append([]byte(*value), false)
+		}
+	}
+	return kb.Key(), nil
+}
+
+func (self *BinlogSource) Close() error {
+	if self.cmd.Process != nil {
+		return self.cmd.Process.Kill()
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package mysql
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+const binlogText = `# at 1234
+#261018 10:00:00 server id 1  end_log_pos 1300 CRC32 0x12345678 	Table_map: ` + "`db1`.`t1`" + ` mapped to number 100
+### INSERT INTO ` + "`db1`.`t1`" + `
+### SET
+###   @1=-1 (4294967295) /* INT meta=0 nullable=0 is_null=0 */
+###   @2='a\x27b\x5cc\x0a' /* VARSTRING(80) meta=80 nullable=1 is_null=0 */
+###   @3=NULL /* VARSTRING(80) meta=80 nullable=1 is_null=1 */
+### UPDATE ` + "`db1`.`t2`" + `
+### WHERE
+###   @1=2 /* INT meta=0 nullable=0 is_null=0 */
+###   @2='x y' /* VARSTRING(80) meta=80 nullable=1 is_null=0 */
+### SET
+###   @1=3 /* INT meta=0 nullable=0 is_null=0 */
+###   @2='x y' /* VARSTRING(80) meta=80 nullable=1 is_null=0 */
+# at 1400
+### DELETE FROM ` + "`db2`.`t3`" + `
+### WHERE
+###   @1='2026-10-18 10:00:00' /* DATETIME(0) meta=0 nullable=0 is_null=0 */
+`
+
+func TestBinlogParser(t *testing.T) {
+	parser := NewBinlogParser(strings.NewReader(binlogText))
+	var rows []*BinlogRow
+	for {
+		row, err := parser.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		rows = append(rows, row)
+	}
+
+	if len(rows) != 4 {
+		t.Fatalf("got %d rows, want 4", len(rows))
+	}
+
+	r := rows[0]
+	if r.Db != "db1" || r.Table != "t1" || *r.Values[1] != "-1" || r.Unsigned[1] != "4294967295" {
+		t.Fatalf("unexpected row: %+v", r)
+	}
+	if *r.Values[2] != "a'b\\c\n" || r.Values[3] != nil {
+		t.Fatalf("unexpected values: %q %v", *r.Values[2], r.Values[3])
+	}
+
+	if rows[1].Table != "t2" || *rows[1].Values[1] != "2" || *rows[1].Values[2] != "x y" || *rows[2].Values[1] != "3" {
+		t.Fatalf("unexpected update rows: %+v %+v", rows[1], rows[2])
+	}
+	if rows[3].Db != "db2" || *rows[3].Values[1] != "2026-10-18 10:00:00" {
+		t.Fatalf("unexpected delete row: %+v", rows[3])
+	}
+
+	tb := &binlogTable{positions: []int{1, 3}, unsigned: []bool{true, false}, timestamp: []bool{false, false}}
+	if key, err := tb.key(rows[0]); err != nil || key != `4294967295,\N` {
+		t.Fatalf("unexpected key: %s %v", key, err)
+	}
+	tb = &binlogTable{positions: []int{4}, unsigned: []bool{false}, timestamp: []bool{false}}
+	if _, err := tb.key(rows[0]); err == nil {
+		t.Fatal("expected missing key column")
+	}
+
+	//TIMESTAMP主键转换成时间
+	value := "1760752800.123"
+	row := &BinlogRow{Values: map[int]*string{1: &value}}
+	tb = &binlogTable{positions: []int{1}, unsigned: []bool{false}, timestamp: []bool{true}, fromUnix: func(v string) (string, error) {
+		return "converted " + v, nil
+	}}
+	if key, err := tb.key(row); err != nil || key != "converted 1760752800.123" {
+		t.Fatalf("unexpected key: %s %v", key, err)
+	}
+	source := &BinlogSource{}
+	for _, v := range []string{"1e9", "-1", "1;select 1", ""} {
+		if _, err := source.fromUnixTime(v); err == nil {
+			t.Errorf("fromUnixTime(%q) expected an error", v)
+		}
+	}
+	if v, err := source.fromUnixTime("0"); err != nil || v != "0000-00-00 00:00:00" {
+		t.Fatalf("fromUnixTime(0) = %s %v", v, err)
+	}
+}
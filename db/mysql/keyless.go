@@ -0,0 +1,117 @@
+package mysql
+
+import (
+	"checkData/model"
+	"checkData/util"
+	"database/sql"
+	"fmt"
+	"github.com/gookit/slog"
+	"strconv"
+	"strings"
+)
+
+/*
+没有主键和非空唯一索引的表(Keyless)，所有列都作为键，两端按整行数据排序后对比行的多重集合:
+相同的行在键的最后加上出现的序号(第一次出现不加)，如 (1,'a') 出现3次 -> 1,a  1,a,2  1,a,3
+按所有列排序后相同的行相邻，只和上一行比较，不需要在内存中保存整个表，见model.PullKeylessRows
+两端相同的行数不一致时，多出的行出现在.tmore/.tlost中，修复SQL使用整行数据作为条件
+*/
+
+func (self *Table) pullDataKeyless(conn *sql.DB, sqlText string, dataCh chan<- *model.Data, doneCh <-chan struct{}, rows *int) error {
+	stopped, err := model.PullKeylessRows(conn, sqlText, len(self.Keys), dataCh, doneCh, rows)
+	if err != nil {
+		return fmt.Errorf("pullDataKeyless -> %w", err)
+	}
+	if stopped {
+		slog.Infof("收到停止信号，结束数据下载[%s.%s]", self.DbName, self.TbName)
+	}
+	return nil
+}
+
+func (self *Table) parseKeylessId(id model.Key) ([]any, int, error) {
+	//拆分出整行数据和出现的序号
+	values, err := id.Values()
+	if err != nil {
+		return nil, 0, fmt.Errorf("parseKeylessId -> %w", err)
+	}
+	switch len(values) {
+	case len(self.Keys):
+		return values, 1, nil
+	case len(self.Keys) + 1:
+		seq, err := strconv.Atoi(values[len(self.Keys)].(string))
+		if err != nil {
+			return nil, 0, fmt.Errorf("parseKeylessId -> %w", err)
+		}
+		return values[:len(self.Keys)], seq, nil
+	default:
+		return nil, 0, fmt.Errorf("parseKeylessId:列数不一致 id:[%s]", id)
+	}
+}
+
+func (self *Table) countRows(conn *sql.DB, tbName string, keys []string, values []any) (int, error) {
+	//统计和整行数据相同的行数
+	whereClause, err := util.GenerateWhereClause(keys, values, quote, self.escapeValue)
+	if err != nil {
+		return 0, fmt.Errorf("countRows -> %w", err)
+	}
+	rows, err := util.QueryReturnList(conn, fmt.Sprintf("select count(*) from %s where %s", tbName, whereClause))
+	if err != nil {
+		return 0, fmt.Errorf("countRows -> %w", err)
+	}
+	return strconv.Atoi(rows[0][0])
+}
+
+func (self *Table) recheckKeyless(id model.Key) bool {
+	//复核第seq次出现的行，两端都有或者都没有时通过
+	values, seq, err := self.parseKeylessId(id)
+	if err != nil {
+		slog.Errorf("[%s.%s] 复核失败：%s", self.DbName, self.TbName, err)
+		return false
+	}
+	sourceCount, err := self.countRows(self.DbGroup.SourceDbConn, self.EnclosedTbName, self.Keys, values)
+	if err != nil {
+		slog.Errorf("[%s.%s] 复核不一致的数据，查询Source端报错：%s", self.DbName, self.TbName, err)
+		return false
+	}
+	targetCount, err := self.countRows(self.DbGroup.TargetDbConn, self.EnclosedTargetTbName, self.TargetKeys, values)
+	if err != nil {
+		slog.Errorf("[%s.%s] 复核不一致的数据，查询Target端报错：%s", self.DbName, self.TbName, err)
+		return false
+	}
+	//两端都查不到时，整行条件无法匹配(如浮点数、JSON列)，不能认为一致
+	if sourceCount == 0 && targetCount == 0 {
+		slog.Warnf("[%s.%s] 按整行数据查询不到这一行(浮点数、JSON等列无法按值匹配)，复核不通过 id:[%s]", self.DbName, self.TbName, id)
+		return false
+	}
+	if (sourceCount >= seq) == (targetCount >= seq) {
+		slog.Infof("[%s.%s] 数据一致,复核通过 id:[%s] rows:[%d] vs [%d]", self.DbName, self.TbName, id, sourceCount, targetCount)
+		return true
+	}
+	slog.Infof("[%s.%s] 两端相同的行数不一致，复核不通过 id:[%s] rows:[%d] vs [%d]", self.DbName, self.TbName, id, sourceCount, targetCount)
+	return false
+}
+
+func (self *Table) getKeylessRepairSQL(id model.Key, mode int) (string, error) {
+	//每个id只插入或删除一行，同一行多出几次就有几条SQL
+	values, _, err := self.parseKeylessId(id)
+	if err != nil {
+		return "", fmt.Errorf("getKeylessRepairSQL -> %w", err)
+	}
+	switch mode {
+	case -1:
+		whereClause, err := util.GenerateWhereClause(self.TargetKeys, values, quote, self.escapeValue)
+		if err != nil {
+			return "", fmt.Errorf("getKeylessRepairSQL -> %w", err)
+		}
+		return self.getDeleteOneSQL(whereClause), nil
+	case 1:
+		row := util.EncloseValues(values, self.escapeValue)
+		return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s);\n", self.EnclosedTargetTbName, self.TargetKeysText, strings.Join(row, ", ")), nil
+	default:
+		return "", fmt.Errorf("getKeylessRepairSQL:没有主键的表不需要update mode %d", mode)
+	}
+}
+
+func (self *Table) getDeleteOneSQL(whereClause string) string {
+	return fmt.Sprintf("DELETE FROM %s WHERE %s LIMIT 1;\n", self.EnclosedTargetTbName, whereClause)
+}
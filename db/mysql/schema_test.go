@@ -0,0 +1,37 @@
+package mysql
+
+import (
+	"checkData/model"
+	"strings"
+	"testing"
+)
+
+func TestGetAlterDDL(t *testing.T) {
+	rows := [][]any{
+		{"id", "bigint", "NO", nil, "auto_increment", nil, nil, ""},
+		{"name", "varchar(20)", "YES", "a'b", "", "utf8mb4", "utf8mb4_bin", "用户名"},
+		{"updated_at", "datetime", "NO", "CURRENT_TIMESTAMP", "DEFAULT_GENERATED on update CURRENT_TIMESTAMP", nil, nil, ""},
+		{"total", "int", "YES", nil, "STORED GENERATED", nil, nil, ""},
+	}
+	columns := model.BuildColumns(rows)
+	for i, row := range rows {
+		setColumnAttrs(&columns[i], row[4:])
+	}
+
+	tb := &Table{EnclosedTargetTbName: "`t1`"}
+	var diffs []model.SchemaDiff
+	for i := range columns {
+		diffs = append(diffs, model.SchemaDiff{Kind: "column_type", Name: columns[i].Name, Column: &columns[i]})
+	}
+	ddl := tb.GetAlterDDL(diffs)
+	for _, s := range []string{
+		"ALTER TABLE `t1` MODIFY COLUMN `id` bigint NOT NULL AUTO_INCREMENT;\n",
+		"ALTER TABLE `t1` MODIFY COLUMN `name` varchar(20) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin DEFAULT 'a''b' COMMENT '用户名';\n",
+		"ALTER TABLE `t1` MODIFY COLUMN `updated_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP;\n",
+		"-- ALTER TABLE `t1` MODIFY COLUMN `total` int;\n",
+	} {
+		if !strings.Contains(ddl, s) {
+			t.Fatalf("%s not found in:\n%s", s, ddl)
+		}
+	}
+}
@@ -11,19 +11,36 @@ import (
 const quote = "`"
 
 type Table struct {
-	DbName         string
-	TbName         string
-	EnclosedTbName string
-	Mode           string //fast,slow,count
-	Keys           []string
-	Columns        []string
-	Where          string
-	SkipColumns    []string
-	KeysText       string
-	ColumnsText    string
-	SQLText        string
-	DbGroup        *Database
-	Result         *model.Result
+	DbName               string
+	TbName               string
+	EnclosedTbName       string
+	TargetTbName         string //目标端的表名，没有映射时和TbName相同
+	EnclosedTargetTbName string
+	Mode                 string //fast,slow,count
+	Keys                 []string
+	Columns              []string
+	ColumnMap            map[string]string //源端列名 -> 目标端列名
+	TargetKeys           []string
+	TargetColumns        []string
+	Where                string
+	TargetWhere          string //目标端的过滤条件，由Options.TargetFilter确定
+	SkipColumns          []string
+	KeysText             string
+	ColumnsText          string
+	TargetKeysText       string
+	TargetColumnsText    string
+	SQLText              string
+	TargetSQLText        string
+	Hash                 string //摘要算法
+	hashFunc             func([]byte) string
+	Rules                map[string]*model.ColumnRule //源端列名 -> 规范化规则
+	sourceRules          []*model.ColumnRule          //和Columns按位置对应的规范化规则
+	targetRules          []*model.ColumnRule          //目标端的规范化规则，不做时间偏移
+	Keyless              bool                         //没有主键和非空唯一索引，所有列都作为键，按行的多重集合对比
+	Chunk                *model.Chunk                 //数据块，nil表示核对整个表
+	DbGroup              *Database
+	Result               *model.Result
+	recheckRows          map[model.Key][2]map[string]string //最后一次复核时查询到的两端数据，行不存在时为nil
 }
 
 func (self *Table) GetDbName() string {
@@ -36,6 +53,7 @@ func (self *Table) GetTbName() string {
 
 func (self *Table) getEnclosedTbName() {
 	self.EnclosedTbName = util.EncloseStr(self.TbName, quote)
+	self.EnclosedTargetTbName = util.EncloseStr(self.TargetTbName, quote)
 	//schema, tb := self.splitTableName()
 	//self.EnclosedTbName = util.EncloseStr(schema, quote) + "." + util.EncloseStr(tb, quote)
 }
@@ -57,10 +75,35 @@ func (self *Table) getKeys() error {
 		}
 	}
 
+	//没有主键时使用非空的唯一索引
+	if len(self.Keys) == 0 {
+		name, keys, err := self.getUniqueKeys()
+		if err != nil {
+			return fmt.Errorf("getKeys -> %w", err)
+		}
+		if len(keys) > 0 {
+			slog.Infof("[%s.%s] 没有主键，使用唯一索引 %s", self.DbName, self.TbName, name)
+			self.Keys = keys
+		}
+	}
+
 	slog.Infof("[%s.%s] 主键列: %s", self.DbName, self.TbName, strings.Join(self.Keys, ", "))
 	return nil
 }
 
+func (self *Table) getUniqueKeys() (string, []string, error) {
+	//查询唯一索引的列和列是否可空，选择列最少的非空唯一索引
+	sql := fmt.Sprintf(`select s.INDEX_NAME, s.COLUMN_NAME, c.IS_NULLABLE from information_schema.STATISTICS s
+join information_schema.COLUMNS c on c.TABLE_SCHEMA = s.TABLE_SCHEMA and c.TABLE_NAME = s.TABLE_NAME and c.COLUMN_NAME = s.COLUMN_NAME
+where s.TABLE_SCHEMA = database() and s.TABLE_NAME = '%s' and s.NON_UNIQUE = 0 order by s.INDEX_NAME, s.SEQ_IN_INDEX`, self.escapeValue(self.TbName))
+	rows, err := util.QueryReturnList(self.DbGroup.SourceDbConn, sql)
+	if err != nil {
+		return "", nil, fmt.Errorf("getUniqueKeys -> %w", err)
+	}
+	name, keys := model.PickUniqueKey(rows)
+	return name, keys, nil
+}
+
 func (self *Table) getColumns() error {
 	// 获取列名
 	sql := fmt.Sprintf("desc %s", self.EnclosedTbName)
@@ -78,21 +121,96 @@ func (self *Table) getColumns() error {
 
 func (self *Table) getCheckSQL() error {
 
-	var sql string
-	if self.Mode == "slow" {
-		sql = fmt.Sprintf("select %s, %s from %s", self.KeysText, self.ColumnsText, self.EnclosedTbName)
-	} else {
-		sql = fmt.Sprintf("select concat_ws(',',%s) pk,crc32(concat_ws('|',%s)) chksum from %s", self.KeysText, self.ColumnsText, self.EnclosedTbName)
+	sql, err := self.getSelectSQL(self.EnclosedTbName, self.KeysText, self.ColumnsText, self.Columns, self.sourceRules)
+	if err != nil {
+		return fmt.Errorf("getCheckSQL -> %w", err)
+	}
+	targetSQL, err := self.getSelectSQL(self.EnclosedTargetTbName, self.TargetKeysText, self.TargetColumnsText, self.TargetColumns, self.targetRules)
+	if err != nil {
+		return fmt.Errorf("getCheckSQL -> %w", err)
 	}
 
-	if self.Where != "" {
-		sql += " where " + self.Where
+	self.SQLText, self.TargetSQLText = sql, targetSQL
+	if where := self.getWhere(); where != "" {
+		self.SQLText += " where " + where
 	}
-	self.SQLText = sql + " order by " + self.KeysText
+	if where := self.getTargetWhere(); where != "" {
+		self.TargetSQLText += " where " + where
+	}
+	self.SQLText += " order by " + self.KeysText
+	self.TargetSQLText += " order by " + self.TargetKeysText
 
 	return nil
 }
 
+func (self *Table) getSelectSQL(tbName, keysText, columnsText string, columns []string, rules []*model.ColumnRule) (string, error) {
+	//生成一端的核对SQL，两端的列顺序相同，列名可能不同
+	if self.Keyless {
+		//整行数据作为键，不需要摘要
+		return fmt.Sprintf("select %s from %s", keysText, tbName), nil
+	}
+	if self.Mode == "slow" {
+		return fmt.Sprintf("select %s, %s from %s", keysText, columnsText, tbName), nil
+	}
+	sumExpr, err := self.getSumExpr(self.getRowExpr(columns, rules))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("select %s,%s chksum from %s", keysText, sumExpr, tbName), nil
+}
+
+func (self *Table) getRowExpr(columns []string, rules []*model.ColumnRule) string {
+	//拼接数据的表达式，每个值带长度前缀，NULL使用N标记，规则和util.AppendEncodedValue一致，有规范化规则的列先规范化
+	list := make([]string, 0, len(columns))
+	for i, c := range columns {
+		col := ruleExpr(util.EncloseStr(c, quote), rules[i])
+		list = append(list, fmt.Sprintf("ifnull(concat(char_length(%s),':',%s),'N')", col, col))
+	}
+	return fmt.Sprintf("concat(%s)", strings.Join(list, ","))
+}
+
+func (self *Table) getSumExpr(text string) (string, error) {
+	//在数据库侧计算摘要的表达式，结果要和本地计算的格式一致
+	switch self.Hash {
+	case "", "crc32":
+		return fmt.Sprintf("crc32(%s)", text), nil
+	case "md5":
+		return fmt.Sprintf("md5(%s)", text), nil
+	case "sha256":
+		return fmt.Sprintf("left(sha2(%s,256),32)", text), nil
+	default:
+		return "", fmt.Errorf("getSumExpr:fast模式不支持%s算法，请使用slow模式", self.Hash)
+	}
+}
+
+func (self *Table) getBoundarySQL(where string, offset int) string {
+	//获取数据块的边界值
+	col := util.EncloseStr(self.Keys[0], quote)
+	sql := fmt.Sprintf("select %s from %s", col, self.EnclosedTbName)
+	if where != "" {
+		sql += " where " + where
+	}
+	return fmt.Sprintf("%s order by %s limit 1 offset %d", sql, col, offset)
+}
+
+func (self *Table) getChunkSumSQL(tbName string, keys []string, columns []string, rules []*model.ColumnRule, where string) string {
+	//数据块的聚合校验和，每行取md5的前64位做异或，行数据包含主键列，两端相同的行顺序不影响结果
+	//没有主键时相同的行会互相抵消，改为求和(sum返回decimal，不会溢出)
+	agg := "bit_xor"
+	if self.Keyless {
+		agg = "sum"
+	}
+	list := make([]string, 0, len(keys)+len(columns))
+	list = append(list, keys...)
+	list = append(list, columns...)
+	rules = append(make([]*model.ColumnRule, len(keys)), rules...)
+	sql := fmt.Sprintf("select count(*),ifnull(%s(cast(conv(left(md5(%s),16),16,10) as unsigned)),0) from %s", agg, self.getRowExpr(list, rules), tbName)
+	if where != "" {
+		sql += " where " + where
+	}
+	return sql
+}
+
 func (self *Table) escapeValue(val string) string {
 	// 此函数用于转义 值中的单引号和反斜杠等，生成修复SQL时需要使用
 	// 值中的 ' -> ''
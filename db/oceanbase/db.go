@@ -57,9 +57,12 @@ func (self *Database) PreCheck() (err error) {
 			return fmt.Errorf("GetToCheck -> %w", err)
 		}
 
-		//目标库不存在的表
+		//目标库不存在的表，源端表名按映射规则转换成目标端表名后再匹配
+		var mapped []string
 		for _, t := range self.Tables.Source {
-			if !util.InSlice(t, self.Tables.Target) {
+			tt := self.Option.TargetTable(t)
+			mapped = append(mapped, tt)
+			if !util.InSlice(tt, self.Tables.Target) {
 				self.Tables.SourceMore = append(self.Tables.SourceMore, t)
 			} else {
 				self.Tables.ToCheck = append(self.Tables.ToCheck, t)
@@ -68,7 +71,7 @@ func (self *Database) PreCheck() (err error) {
 
 		//源库不存在的表
 		for _, t := range self.Tables.Target {
-			if !util.InSlice(t, self.Tables.Source) {
+			if !util.InSlice(t, mapped) {
 				self.Tables.TargetMore = append(self.Tables.TargetMore, t)
 			}
 		}
@@ -92,15 +95,21 @@ func (self *Database) GetTableInfo() *model.TableInfo {
 }
 
 func (self *Database) NewTable(tb string) model.Table {
+	opt := self.Option.ForTable(tb)
 	return &Table{
-		DbName:      self.TargetDb,
-		TbName:      tb,
-		Mode:        self.Option.Mode,
-		SkipColumns: self.Option.SkipColList,
-		Keys:        self.Option.KeysList,
-		Where:       self.Option.Where,
-		DbGroup:     self,
-		Result:      &model.Result{DbName: self.TargetDb, TbName: tb, RecheckPassRows: -1},
+		DbName:       self.TargetDb,
+		TbName:       tb,
+		TargetTbName: self.Option.TargetTable(tb),
+		ColumnMap:    opt.ColumnMapList,
+		Rules:        opt.RuleList,
+		Mode:         self.Option.Mode,
+		SkipColumns:  opt.SkipColList,
+		Keys:         opt.KeysList,
+		Where:        opt.SourceFilter(),
+		TargetWhere:  opt.TargetFilter(),
+		Hash:         self.Option.Hash,
+		DbGroup:      self,
+		Result:       &model.Result{DbName: self.TargetDb, TbName: tb, RecheckPassRows: -1},
 	}
 }
 
@@ -113,11 +122,21 @@ func (self *Database) Close() {
 
 func NewDatabase(opt *model.Options, dbg [2]string) (model.Database, error) {
 	slog.Infof("[%s:%s] 开启数据库连接池", dbg[0], dbg[1])
-	sdb, err := util.NewOceanbaseDB(opt.SourceHost, opt.SourcePort, opt.User, opt.Password, dbg[0])
+	var sdb, tdb *sql.DB
+	var err error
+	if opt.SourceDsn != "" {
+		sdb, err = util.OpenDsn("oceanbase", opt.SourceDsn, dbg[0])
+	} else {
+		sdb, err = util.NewOceanbaseDB(opt.SourceHost, opt.SourcePort, opt.User, opt.Password, dbg[0])
+	}
 	if err != nil {
 		return nil, fmt.Errorf("NewDatabase -> %w", err)
 	}
-	tdb, err := util.NewOceanbaseDB(opt.TargetHost, opt.TargetPort, opt.TargetUser, opt.TargetPassword, dbg[1])
+	if opt.TargetDsn != "" {
+		tdb, err = util.OpenDsn("oceanbase", opt.TargetDsn, dbg[1])
+	} else {
+		tdb, err = util.NewOceanbaseDB(opt.TargetHost, opt.TargetPort, opt.TargetUser, opt.TargetPassword, dbg[1])
+	}
 	if err != nil {
 		return nil, fmt.Errorf("NewDatabase -> %w", err)
 	}
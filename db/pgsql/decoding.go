@@ -0,0 +1,411 @@
+package pgsql
+
+import (
+	"checkData/model"
+	"checkData/util"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/gookit/slog"
+	"io"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// decodedChange 逻辑解码得到的一行数据的变更，Tuples为旧主键和新数据，列名 -> 值，nil表示NULL
+type decodedChange struct {
+	Table  string
+	Tuples []map[string]*string
+}
+
+// ChangeFetcher 读取逻辑复制槽中的变更文本，测试时可以替换成内存中的实现
+// Fetch只读取不消费，Advance之前再次Fetch返回同一批变更；Advance消费最后一次Fetch读取到的变更
+type ChangeFetcher interface {
+	Fetch() ([]string, error)
+	Advance() error
+}
+
+type slotFetcher struct {
+	conn  *sql.DB
+	slot  string
+	limit int    //每批最多读取的变更数，按事务返回，可能超过这个数量
+	lsn   string //最后一次读取到的位置
+}
+
+func (self *slotFetcher) Fetch() ([]string, error) {
+	//读取复制槽中的变更，复核完成后才使用Advance消费
+	rows, err := util.QueryReturnList(self.conn, fmt.Sprintf("select lsn::text, data from pg_logical_slot_peek_changes('%s', NULL, %d)", self.slot, self.limit))
+	if err != nil {
+		return nil, fmt.Errorf("Fetch -> %w", err)
+	}
+	list := make([]string, 0, len(rows))
+	for _, row := range rows {
+		list = append(list, row[1])
+	}
+	if len(rows) > 0 {
+		self.lsn = rows[len(rows)-1][0]
+	}
+	return list, nil
+}
+
+func (self *slotFetcher) Advance() error {
+	//最后一行是事务的提交位置，移动到这个位置后这一批事务不再输出
+	if self.lsn == "" {
+		return nil
+	}
+	if _, err := self.conn.Exec(fmt.Sprintf("select pg_replication_slot_advance('%s', '%s')", self.slot, self.lsn)); err != nil {
+		return fmt.Errorf("Advance -> %w", err)
+	}
+	self.lsn = ""
+	return nil
+}
+
+// LogicalSource 轮询源端的逻辑复制槽，解析出需要核对的表中有变更的主键，支持test_decoding和wal2json(format-version 1)
+type LogicalSource struct {
+	Fetcher  ChangeFetcher
+	Plugin   string
+	ToCheck  []string
+	GetKeys  func(tbName string) ([]string, error)
+	Interval time.Duration //复制槽中没有变更时的等待时间
+	keys     map[string][]string
+	queue    []*model.Change
+	fetched  bool //已经读取了一批变更，Commit之前不再读取
+	closed   atomic.Bool
+}
+
+func NewLogicalSource(db model.Database, opt *model.Options) (*LogicalSource, error) {
+	pdb, ok := db.(*Database)
+	if !ok {
+		return nil, fmt.Errorf("NewLogicalSource:unsupported database %T", db)
+	}
+
+	//复制槽不存在时创建，从当前位置开始读取
+	rows, err := util.QueryReturnList(pdb.SourceDbConn, fmt.Sprintf("select plugin from pg_replication_slots where slot_name='%s'", opt.Slot))
+	if err != nil {
+		return nil, fmt.Errorf("NewLogicalSource -> %w", err)
+	}
+	if len(rows) == 0 {
+		_, err = pdb.SourceDbConn.Exec(fmt.Sprintf("select pg_create_logical_replication_slot('%s', '%s')", opt.Slot, opt.Plugin))
+		if err != nil {
+			return nil, fmt.Errorf("NewLogicalSource -> %w", err)
+		}
+		slog.Infof("[%s] 创建逻辑复制槽 %s [plugin:%s]", pdb.SourceDb, opt.Slot, opt.Plugin)
+	} else if rows[0][0] != opt.Plugin {
+		return nil, fmt.Errorf("NewLogicalSource:复制槽%s使用的插件为%s", opt.Slot, rows[0][0])
+	}
+
+	return &LogicalSource{
+		Fetcher: &slotFetcher{conn: pdb.SourceDbConn, slot: opt.Slot, limit: 10000},
+		Plugin:  opt.Plugin,
+		ToCheck: pdb.Tables.ToCheck,
+		GetKeys: func(tbName string) ([]string, error) {
+			t := pdb.NewTable(tbName).(*Table)
+			err := t.getKeys()
+			return t.Keys, err
+		},
+		Interval: time.Second,
+	}, nil
+}
+
+func (self *LogicalSource) Next() (*model.Change, error) {
+	for len(self.queue) == 0 {
+		if self.closed.Load() {
+			return nil, io.EOF
+		}
+		if self.fetched {
+			//这一批变更都已返回，复核完成并Commit后再读取下一批
+			return nil, model.ErrCommitRequired
+		}
+
+		list, err := self.Fetcher.Fetch()
+		if err != nil {
+			return nil, fmt.Errorf("LogicalSource.Next -> %w", err)
+		}
+		if len(list) == 0 {
+			time.Sleep(self.Interval)
+			continue
+		}
+		self.fetched = true
+
+		for _, text := range list {
+			changes, err := self.decode(text)
+			if err != nil {
+				slog.Warnf("解析逻辑解码的输出报错，忽略这个变更：%s", err)
+				continue
+			}
+			for _, c := range changes {
+				self.addChange(c)
+			}
+		}
+	}
+
+	change := self.queue[0]
+	self.queue = self.queue[1:]
+	return change, nil
+}
+
+func (self *LogicalSource) Commit() error {
+	//消费已经读取的一批变更
+	if !self.fetched {
+		return nil
+	}
+	if err := self.Fetcher.Advance(); err != nil {
+		return fmt.Errorf("LogicalSource.Commit -> %w", err)
+	}
+	self.fetched = false
+	return nil
+}
+
+func (self *LogicalSource) Close() error {
+	//复制槽会保留没有读取的WAL，不再使用时需要手工删除: select pg_drop_replication_slot('slot')
+	self.closed.Store(true)
+	return nil
+}
+
+func (self *LogicalSource) decode(text string) ([]*decodedChange, error) {
+	switch self.Plugin {
+	case "wal2json":
+		return parseWal2json(text)
+	default:
+		c, err := parseTestDecoding(text)
+		if c == nil || err != nil {
+			return nil, err
+		}
+		return []*decodedChange{c}, nil
+	}
+}
+
+func (self *LogicalSource) addChange(c *decodedChange) {
+	//只处理需要核对的表，取出旧主键和新主键
+	if !util.InSlice(c.Table, self.ToCheck) {
+		return
+	}
+	keys, err := self.getKeys(c.Table)
+	if err != nil {
+		return
+	}
+
+	seen := make(map[model.Key]bool)
+	for _, tuple := range c.Tuples {
+		var kb model.KeyBuilder
+		complete := true
+		for _, k := range keys {
+			v, ok := tuple[k]
+			if !ok {
+				complete = false
+				break
+			}
+			if v == nil {
+				kb.Append(nil, true)
+			} else {
+				kb.Append([]byte(*v), false)
+			}
+		}
+		if !complete {
+			//镜像中缺少主键列，如toast列作为主键且没有修改
+			continue
+		}
+		if key := kb.Key(); !seen[key] {
+			seen[key] = true
+			self.queue = append(self.queue, &model.Change{Table: c.Table, Key: key})
+		}
+	}
+	if len(seen) == 0 {
+		slog.Warnf("[%s] 逻辑解码的输出中缺少主键列，忽略这行变更", c.Table)
+	}
+}
+
+func (self *LogicalSource) getKeys(tbName string) ([]string, error) {
+	if self.keys == nil {
+		self.keys = make(map[string][]string)
+	}
+	if keys, ok := self.keys[tbName]; ok {
+		if keys == nil {
+			return nil, fmt.Errorf("getKeys:Keys is empty")
+		}
+		return keys, nil
+	}
+
+	keys, err := self.GetKeys(tbName)
+	if err == nil && len(keys) == 0 {
+		err = fmt.Errorf("getKeys:Keys is empty")
+	}
+	if err != nil {
+		slog.Errorf("[%s] 获取主键列报错，忽略这个表的变更：%s", tbName, err)
+		keys = nil
+	}
+	self.keys[tbName] = keys
+	return keys, err
+}
+
+// parseTestDecoding 解析test_decoding的输出，BEGIN/COMMIT等返回nil，例如:
+//
+//	table public.t1: INSERT: id[integer]:1 name[text]:'it''s'
+//	table public.t1: UPDATE: old-key: id[integer]:1 new-tuple: id[integer]:2 name[text]:null
+//	table public.t1: DELETE: id[integer]:2
+func parseTestDecoding(text string) (*decodedChange, error) {
+	if !strings.HasPrefix(text, "table ") {
+		return nil, nil
+	}
+	text = text[len("table "):]
+
+	i := strings.Index(text, ": ")
+	if i < 0 {
+		return nil, fmt.Errorf("parseTestDecoding:invalid text %s", text)
+	}
+	c := &decodedChange{Table: strings.ReplaceAll(text[:i], `"`, "")}
+	text = text[i+2:]
+
+	i = strings.Index(text, ": ")
+	if i < 0 {
+		//没有数据，如 TRUNCATE: (no-flags)
+		return nil, nil
+	}
+	text = text[i+2:]
+
+	tuple := make(map[string]*string)
+	for len(text) > 0 {
+		text = strings.TrimLeft(text, " ")
+		if strings.HasPrefix(text, "old-key: ") || strings.HasPrefix(text, "new-tuple: ") {
+			if len(tuple) > 0 {
+				c.Tuples = append(c.Tuples, tuple)
+				tuple = make(map[string]*string)
+			}
+			text = text[strings.Index(text, ": ")+2:]
+			continue
+		}
+		if strings.HasPrefix(text, "(no-tuple-data)") {
+			break
+		}
+
+		//列名[类型]:值
+		i = strings.IndexByte(text, '[')
+		if i < 0 {
+			return nil, fmt.Errorf("parseTestDecoding:invalid column %s", text)
+		}
+		name := strings.ReplaceAll(text[:i], `"`, "")
+		depth := 0
+		j := i
+		for ; j < len(text); j++ {
+			if text[j] == '[' {
+				depth++
+			} else if text[j] == ']' {
+				depth--
+				if depth == 0 {
+					break
+				}
+			}
+		}
+		if j+1 >= len(text) || text[j+1] != ':' {
+			return nil, fmt.Errorf("parseTestDecoding:invalid column %s", text)
+		}
+		text = text[j+2:]
+
+		var value *string
+		if strings.HasPrefix(text, "'") {
+			var buf strings.Builder
+			k := 1
+			for ; k < len(text); k++ {
+				if text[k] == '\'' {
+					if k+1 < len(text) && text[k+1] == '\'' {
+						buf.WriteByte('\'')
+						k++
+						continue
+					}
+					break
+				}
+				buf.WriteByte(text[k])
+			}
+			if k >= len(text) {
+				return nil, fmt.Errorf("parseTestDecoding:invalid value %s", text)
+			}
+			s := buf.String()
+			value = &s
+			text = text[k+1:]
+		} else {
+			s, rest, _ := strings.Cut(text, " ")
+			text = rest
+			if s == "unchanged-toast-datum" {
+				continue
+			}
+			if s != "null" {
+				value = &s
+			}
+		}
+		tuple[name] = value
+	}
+	if len(tuple) > 0 {
+		c.Tuples = append(c.Tuples, tuple)
+	}
+	return c, nil
+}
+
+type wal2jsonMessage struct {
+	Change []struct {
+		Kind         string            `json:"kind"`
+		Schema       string            `json:"schema"`
+		Table        string            `json:"table"`
+		ColumnNames  []string          `json:"columnnames"`
+		ColumnValues []json.RawMessage `json:"columnvalues"`
+		OldKeys      *struct {
+			KeyNames  []string          `json:"keynames"`
+			KeyValues []json.RawMessage `json:"keyvalues"`
+		} `json:"oldkeys"`
+	} `json:"change"`
+}
+
+func parseWal2json(text string) ([]*decodedChange, error) {
+	//解析wal2json(format-version 1)的输出，每个事务一个json
+	var msg wal2jsonMessage
+	if err := json.Unmarshal([]byte(text), &msg); err != nil {
+		return nil, fmt.Errorf("parseWal2json -> %w", err)
+	}
+
+	var changes []*decodedChange
+	for _, ch := range msg.Change {
+		c := &decodedChange{Table: ch.Schema + "." + ch.Table}
+		if ch.OldKeys != nil {
+			tuple, err := wal2jsonTuple(ch.OldKeys.KeyNames, ch.OldKeys.KeyValues)
+			if err != nil {
+				return nil, err
+			}
+			c.Tuples = append(c.Tuples, tuple)
+		}
+		if len(ch.ColumnNames) > 0 {
+			tuple, err := wal2jsonTuple(ch.ColumnNames, ch.ColumnValues)
+			if err != nil {
+				return nil, err
+			}
+			c.Tuples = append(c.Tuples, tuple)
+		}
+		if len(c.Tuples) > 0 {
+			changes = append(changes, c)
+		}
+	}
+	return changes, nil
+}
+
+func wal2jsonTuple(names []string, values []json.RawMessage) (map[string]*string, error) {
+	if len(names) != len(values) {
+		return nil, fmt.Errorf("wal2jsonTuple:列名和值的个数不一致")
+	}
+	tuple := make(map[string]*string, len(names))
+	for i, name := range names {
+		raw := values[i]
+		if string(raw) == "null" {
+			tuple[name] = nil
+			continue
+		}
+		var s string
+		if len(raw) > 0 && raw[0] == '"' {
+			if err := json.Unmarshal(raw, &s); err != nil {
+				return nil, fmt.Errorf("wal2jsonTuple -> %w", err)
+			}
+		} else {
+			s = string(raw)
+		}
+		tuple[name] = &s
+	}
+	return tuple, nil
+}
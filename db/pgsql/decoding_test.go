@@ -0,0 +1,156 @@
+package pgsql
+
+import (
+	"checkData/model"
+	"errors"
+	"io"
+	"testing"
+)
+
+// memFetcher 内存中的复制槽，Advance之前Fetch返回同一批变更，空的一批表示暂时没有变更，读完后关闭source
+type memFetcher struct {
+	batches [][]string
+	source  *LogicalSource
+}
+
+func (self *memFetcher) Fetch() ([]string, error) {
+	if len(self.batches) == 0 {
+		self.source.Close()
+		return nil, nil
+	}
+	batch := self.batches[0]
+	if len(batch) == 0 {
+		//复制槽中暂时没有变更
+		self.batches = self.batches[1:]
+	}
+	return batch, nil
+}
+
+func (self *memFetcher) Advance() error {
+	self.batches = self.batches[1:]
+	return nil
+}
+
+func readChanges(t *testing.T, plugin string, batches [][]string) []model.Change {
+	src := &LogicalSource{
+		Plugin:  plugin,
+		ToCheck: []string{"public.t1", "public.t2"},
+		GetKeys: func(tbName string) ([]string, error) {
+			if tbName == "public.t2" {
+				return []string{"a", "b"}, nil
+			}
+			return []string{"id"}, nil
+		},
+	}
+	src.Fetcher = &memFetcher{batches: batches, source: src}
+
+	var changes []model.Change
+	for {
+		c, err := src.Next()
+		if errors.Is(err, model.ErrCommitRequired) {
+			if err := src.Commit(); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		} else if errors.Is(err, io.EOF) {
+			return changes
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		changes = append(changes, *c)
+	}
+}
+
+func TestTestDecoding(t *testing.T) {
+	changes := readChanges(t, "test_decoding", [][]string{
+		{
+			"BEGIN 100",
+			`table public.t1: INSERT: id[integer]:1 name[text]:'it''s a b' tags[text[]]:'{x,y}'`,
+			`table public.t1: UPDATE: id[integer]:1 name[text]:null tags[text[]]:null`,
+			"COMMIT 100",
+		},
+		{},
+		{
+			`table public.t1: UPDATE: old-key: id[integer]:1 new-tuple: id[integer]:2 name[text]:'x'`,
+			`table public.t2: DELETE: a[character varying]:'p,q' b[integer]:null`,
+			`table public.t3: INSERT: id[integer]:9`,
+			`table public.t1: TRUNCATE: (no-flags)`,
+		},
+	})
+
+	want := []model.Change{
+		{Table: "public.t1", Key: "1"},
+		{Table: "public.t1", Key: "1"},
+		{Table: "public.t1", Key: "1"},
+		{Table: "public.t1", Key: "2"},
+		{Table: "public.t2", Key: `p\,q,\N`},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("got %v, want %v", changes, want)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Fatalf("change %d: got %v, want %v", i, changes[i], want[i])
+		}
+	}
+}
+
+func TestWal2json(t *testing.T) {
+	changes := readChanges(t, "wal2json", [][]string{{
+		`{"change":[` +
+			`{"kind":"insert","schema":"public","table":"t1","columnnames":["id","name"],"columnvalues":[10,"a"]},` +
+			`{"kind":"update","schema":"public","table":"t1","columnnames":["id","name"],"columnvalues":[11,null],"oldkeys":{"keynames":["id"],"keyvalues":[10]}},` +
+			`{"kind":"delete","schema":"public","table":"t2","oldkeys":{"keynames":["a","b"],"keyvalues":["x",1]}}` +
+			`]}`,
+	}})
+
+	want := []model.Change{
+		{Table: "public.t1", Key: "10"},
+		{Table: "public.t1", Key: "10"},
+		{Table: "public.t1", Key: "11"},
+		{Table: "public.t2", Key: "x,1"},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("got %v, want %v", changes, want)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Fatalf("change %d: got %v, want %v", i, changes[i], want[i])
+		}
+	}
+}
+
+func TestCommitBeforeNextBatch(t *testing.T) {
+	src := &LogicalSource{
+		Plugin:  "test_decoding",
+		ToCheck: []string{"public.t1"},
+		GetKeys: func(string) ([]string, error) { return []string{"id"}, nil },
+	}
+	fetcher := &memFetcher{batches: [][]string{
+		{`table public.t1: INSERT: id[integer]:1`},
+		{`table public.t1: INSERT: id[integer]:2`},
+	}, source: src}
+	src.Fetcher = fetcher
+
+	c, err := src.Next()
+	if err != nil || c.Key != "1" {
+		t.Fatalf("Next() = %v, %v", c, err)
+	}
+	//没有Commit时不读取下一批，复制槽中保留这一批变更
+	for i := 0; i < 2; i++ {
+		if _, err := src.Next(); !errors.Is(err, model.ErrCommitRequired) {
+			t.Fatalf("Next() error = %v, want ErrCommitRequired", err)
+		}
+	}
+	if len(fetcher.batches) != 2 {
+		t.Fatalf("advanced before commit: %v", fetcher.batches)
+	}
+
+	if err := src.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	c, err = src.Next()
+	if err != nil || c.Key != "2" || len(fetcher.batches) != 1 {
+		t.Fatalf("Next() = %v, %v, batches = %v", c, err, fetcher.batches)
+	}
+}
@@ -0,0 +1,105 @@
+package pgsql
+
+import (
+	"checkData/model"
+	"checkData/util"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+func (self *Table) getTableDef(conn *sql.DB, tbName string) (*model.TableDef, error) {
+	//从pg_catalog读取表结构，默认值是pg_get_expr返回的表达式
+	schema, tb := splitTableName(tbName)
+	schema, tb = self.escapeValue(schema), self.escapeValue(tb)
+	sqlText := fmt.Sprintf(`select a.attname, format_type(a.atttypid, a.atttypmod), not a.attnotnull, pg_get_expr(d.adbin, d.adrelid)
+from pg_attribute a join pg_class c on c.oid = a.attrelid join pg_namespace n on n.oid = c.relnamespace
+left join pg_attrdef d on d.adrelid = a.attrelid and d.adnum = a.attnum
+where a.attnum > 0 and not a.attisdropped and n.nspname = '%s' and c.relname = '%s' order by a.attnum`, schema, tb)
+	rows, err := util.QueryReturnListWithNil(conn, sqlText)
+	if err != nil {
+		return nil, fmt.Errorf("getTableDef -> %w", err)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("getTableDef:表%s不存在", tbName)
+	}
+	def := &model.TableDef{Columns: model.BuildColumns(rows)}
+
+	sqlText = fmt.Sprintf(`select i.relname, ix.indisunique, ix.indisprimary, a.attname
+from pg_index ix join pg_class c on c.oid = ix.indrelid join pg_namespace n on n.oid = c.relnamespace
+join pg_class i on i.oid = ix.indexrelid join pg_attribute a on a.attrelid = c.oid and a.attnum = any(ix.indkey)
+where n.nspname = '%s' and c.relname = '%s' order by i.relname, array_position(ix.indkey, a.attnum)`, schema, tb)
+	list, err := util.QueryReturnList(conn, sqlText)
+	if err != nil {
+		return nil, fmt.Errorf("getTableDef -> %w", err)
+	}
+	def.Indexes = model.BuildIndexes(list)
+	return def, nil
+}
+
+func (self *Table) GetTableDef() (source *model.TableDef, target *model.TableDef, err error) {
+	self.getEnclosedTbName()
+	source, err = self.getTableDef(self.DbGroup.SourceDbConn, self.TbName)
+	if err != nil {
+		return nil, nil, fmt.Errorf("GetTableDef:source -> %w", err)
+	}
+	target, err = self.getTableDef(self.DbGroup.TargetDbConn, self.TargetTbName)
+	if err != nil {
+		return nil, nil, fmt.Errorf("GetTableDef:target -> %w", err)
+	}
+	return source, target, nil
+}
+
+func (self *Table) GetAlterDDL(diffs []model.SchemaDiff) string {
+	//生成使目标端和源端一致的DDL，删除列和索引的语句被注释，需要确认后再执行
+	var buf strings.Builder
+	tb := self.EnclosedTargetTbName
+	schema, _ := splitTableName(self.TargetTbName)
+	for _, d := range diffs {
+		name := util.EncloseStr(d.Name, quote)
+		switch d.Kind {
+		case "column_missing":
+			text := d.Column.Type
+			if !d.Column.Nullable {
+				text += " NOT NULL"
+			}
+			if d.Column.Default != nil {
+				text += " DEFAULT " + *d.Column.Default
+			}
+			buf.WriteString(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s;\n", tb, name, text))
+		case "column_type":
+			buf.WriteString(fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s TYPE %s;\n", tb, name, d.Column.Type))
+		case "column_nullable":
+			if d.Column.Nullable {
+				buf.WriteString(fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s DROP NOT NULL;\n", tb, name))
+			} else {
+				buf.WriteString(fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET NOT NULL;\n", tb, name))
+			}
+		case "column_default":
+			if d.Column.Default == nil {
+				buf.WriteString(fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s DROP DEFAULT;\n", tb, name))
+			} else {
+				buf.WriteString(fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET DEFAULT %s;\n", tb, name, *d.Column.Default))
+			}
+		case "column_extra":
+			buf.WriteString(fmt.Sprintf("-- ALTER TABLE %s DROP COLUMN %s;\n", tb, name))
+		case "primary_key":
+			//主键约束的名称和主键索引相同
+			if d.Name != "" {
+				buf.WriteString(fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT %s;\n", tb, name))
+			}
+			if d.Index != nil {
+				buf.WriteString(fmt.Sprintf("ALTER TABLE %s ADD PRIMARY KEY (%s);\n", tb, util.EncloseAndJoin(d.Index.Columns, quote)))
+			}
+		case "index_missing":
+			unique := ""
+			if d.Index.Unique {
+				unique = "UNIQUE "
+			}
+			buf.WriteString(fmt.Sprintf("CREATE %sINDEX %s ON %s (%s);\n", unique, name, tb, util.EncloseAndJoin(d.Index.Columns, quote)))
+		case "index_extra":
+			buf.WriteString(fmt.Sprintf("-- DROP INDEX %s.%s;\n", util.EncloseStr(schema, quote), name))
+		}
+	}
+	return buf.String()
+}
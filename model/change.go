@@ -0,0 +1,24 @@
+package model
+
+import "errors"
+
+// Change 源端一行数据的变更，Table为表名，Key为变更前或变更后的主键值
+type Change struct {
+	Table string
+	Key   Key
+}
+
+// ChangeSource 源端的增量变更，Next会阻塞直到有新的变更，变更流结束时返回io.EOF
+type ChangeSource interface {
+	Next() (*Change, error)
+	Close() error
+}
+
+// ErrCommitRequired Next读取完一批变更后返回，这批变更都复核完成并调用Commit之后才能继续读取
+var ErrCommitRequired = errors.New("commit required")
+
+// CommitChangeSource 需要确认消费的变更流，Commit后源端不再保留已经读取的变更
+type CommitChangeSource interface {
+	ChangeSource
+	Commit() error
+}
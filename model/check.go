@@ -13,9 +13,34 @@ type Table interface {
 	PreCheck() bool
 	PullSourceDataSum(chan<- *Data, <-chan struct{})
 	PullTargetDataSum(chan<- *Data, <-chan struct{})
-	Recheck([]string) []string
-	GetRepairSQL(string, int) (string, error)
+	Recheck([]Key) []Key
+	GetRepairSQL(Key, int) (string, error)
 	GetSourceTableCount()
 	GetTargetTableCount()
 	GetResult() *Result
 }
+
+// RepairScriptTable 修复语句不是SQL的表，如mongo生成mongosh脚本，GetRepairFileExt返回修复文件的扩展名
+type RepairScriptTable interface {
+	Table
+	GetRepairFileExt() string
+}
+
+// SchemaTable 支持表结构核对的表，GetTableDef返回两端的表结构，GetAlterDDL生成使目标端和源端一致的DDL
+type SchemaTable interface {
+	Table
+	GetTableDef() (source *TableDef, target *TableDef, err error)
+	GetAlterDDL(diffs []SchemaDiff) string
+}
+
+// RecheckRowTable 复核时保存两端数据的表，GetRecheckRows返回最后一次复核时查询到的数据(格式同GetRows)，没有复核过或者查询报错时ok为false
+type RecheckRowTable interface {
+	Table
+	GetRecheckRows(id Key) (columns []string, source map[string]string, target map[string]string, ok bool)
+}
+
+// RowTable 可以按主键查询两端数据的表，GetRows返回列名和两端的一行数据(目标端使用源端的列名)，行不存在时为nil
+type RowTable interface {
+	Table
+	GetRows(id Key) (columns []string, source map[string]string, target map[string]string, err error)
+}
@@ -0,0 +1,41 @@
+package model
+
+import "fmt"
+
+// Chunk 按第一个主键列的范围拆分的数据块，范围为 [Lower, Upper)，nil表示没有边界
+type Chunk struct {
+	Index int
+	Lower *string
+	Upper *string
+}
+
+func (self *Chunk) String() string {
+	lower, upper := "-inf", "+inf"
+	if self.Lower != nil {
+		lower = *self.Lower
+	}
+	if self.Upper != nil {
+		upper = *self.Upper
+	}
+	return fmt.Sprintf("#%d[%s, %s)", self.Index, lower, upper)
+}
+
+// ChunkTable 支持把一个大表拆分成多个数据块并行核对
+type ChunkTable interface {
+	Table
+	GetChunks(size int) ([]*Chunk, error)
+	NewChunkTable(chunk *Chunk) Table
+}
+
+// ChunkSum 数据块在一端的行数和聚合校验和
+type ChunkSum struct {
+	Rows int
+	Sum  string
+}
+
+// ChunkSumTable 支持在数据库侧计算数据块的聚合校验和，校验和一致的数据块不需要下载明细数据
+type ChunkSumTable interface {
+	ChunkTable
+	GetChunkSum(chunk *Chunk) (source ChunkSum, target ChunkSum, err error)
+	SplitChunk(chunk *Chunk, rows int) ([]*Chunk, error)
+}
@@ -0,0 +1,200 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ColumnPattern 一列不一致的值按模式分类的统计，Percent为这一类在这一列不一致行中的百分比
+type ColumnPattern struct {
+	Column  string
+	Pattern string //null,trailing_space,whitespace,case,json_format,time_format,time_offset:+8h,time_precision,time_diff,numeric_format,numeric_rounding,numeric_diff,truncated,column_missing,other
+	Rows    int
+	Total   int
+	Percent int
+}
+
+func (self ColumnPattern) String() string {
+	return fmt.Sprintf("%s: %d%% 的不一致为 %s (%d/%d)", self.Column, self.Percent, PatternText(self.Pattern), self.Rows, self.Total)
+}
+
+func PatternText(pattern string) string {
+	kind, arg, _ := strings.Cut(pattern, ":")
+	switch kind {
+	case "null":
+		return "一端为NULL"
+	case "trailing_space":
+		return "尾部空格不同"
+	case "whitespace":
+		return "首尾空白不同"
+	case "case":
+		return "大小写不同(字符集/排序规则)"
+	case "json_format":
+		return "JSON的键顺序或格式不同"
+	case "time_offset":
+		return fmt.Sprintf("时间相差%s(时区)", arg)
+	case "time_format":
+		return "时间相同、格式不同"
+	case "time_precision":
+		return "时间的小数秒不同(精度)"
+	case "time_diff":
+		return "时间不同"
+	case "numeric_format":
+		return "数值相同、格式不同"
+	case "numeric_rounding":
+		return "数值的舍入不同(精度)"
+	case "numeric_diff":
+		return "数值不同"
+	case "truncated":
+		return "一端的值被截断"
+	case "column_missing":
+		return "一端没有这一列"
+	}
+	return "其他"
+}
+
+var timeLayouts = []string{
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999 -0700",
+	"2006-01-02 15:04:05.999999999Z07",
+	time.RFC3339Nano,
+	"2006-01-02",
+}
+
+func parseDiffTime(v string) (time.Time, bool) {
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, v); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func decimals(v string) int {
+	if _, frac, ok := strings.Cut(v, "."); ok {
+		return len(strings.TrimRight(frac, "0"))
+	}
+	return 0
+}
+
+func ClassifyDiff(source, target string) string {
+	//按常见的系统性原因给一列两端不同的值分类，先匹配的优先，两端相同(如截断后的值)时无法分类
+	switch {
+	case source == target:
+		return "other"
+	case source == "(无此列)" || target == "(无此列)":
+		return "column_missing"
+	case source == "NULL" || target == "NULL":
+		return "null"
+	case strings.TrimRight(source, " ") == strings.TrimRight(target, " "):
+		return "trailing_space"
+	case strings.TrimSpace(source) == strings.TrimSpace(target):
+		return "whitespace"
+	case strings.EqualFold(source, target):
+		return "case"
+	}
+
+	//JSON对象和数组
+	if s, t := strings.TrimSpace(source), strings.TrimSpace(target); s != "" && t != "" && strings.ContainsAny(s[:1], "{[") && strings.ContainsAny(t[:1], "{[") {
+		var sv, tv any
+		if json.Unmarshal([]byte(s), &sv) == nil && json.Unmarshal([]byte(t), &tv) == nil && reflect.DeepEqual(sv, tv) {
+			return "json_format"
+		}
+	}
+
+	//时间
+	if st, ok := parseDiffTime(source); ok {
+		if tt, ok := parseDiffTime(target); ok {
+			d := tt.Sub(st)
+			switch {
+			case d == 0:
+				return "time_format"
+			case d%time.Hour == 0:
+				return fmt.Sprintf("time_offset:%+dh", int(d/time.Hour))
+			case d < time.Second && d > -time.Second:
+				return "time_precision"
+			}
+			return "time_diff"
+		}
+	}
+
+	//数值，按小数位数少的一端舍入后相同为精度问题
+	if sf, err := strconv.ParseFloat(source, 64); err == nil {
+		if tf, err := strconv.ParseFloat(target, 64); err == nil {
+			if sf == tf {
+				return "numeric_format"
+			}
+			scale := math.Pow10(min(decimals(source), decimals(target)))
+			if math.Round(sf*scale) == math.Round(tf*scale) {
+				return "numeric_rounding"
+			}
+			return "numeric_diff"
+		}
+	}
+
+	if source != "" && target != "" && (strings.HasPrefix(source, target) || strings.HasPrefix(target, source)) {
+		return "truncated"
+	}
+	return "other"
+}
+
+func AnalyzeDiffs(details []DiffDetail) []ColumnPattern {
+	//按列统计不一致的值的模式，结果按列名、行数从多到少排序
+	total := make(map[string]int)
+	counts := make(map[[2]string]int)
+	for _, d := range details {
+		for _, c := range d.Columns {
+			total[c.Column]++
+			//旧版本的diff.jsonl没有Pattern，按保存的值分类
+			pattern := c.Pattern
+			if pattern == "" {
+				pattern = ClassifyDiff(c.Source, c.Target)
+			}
+			counts[[2]string{c.Column, pattern}]++
+		}
+	}
+
+	list := make([]ColumnPattern, 0, len(counts))
+	for k, n := range counts {
+		list = append(list, ColumnPattern{Column: k[0], Pattern: k[1], Rows: n, Total: total[k[0]], Percent: n * 100 / total[k[0]]})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Column != list[j].Column {
+			return list[i].Column < list[j].Column
+		}
+		if list[i].Rows != list[j].Rows {
+			return list[i].Rows > list[j].Rows
+		}
+		return list[i].Pattern < list[j].Pattern
+	})
+	return list
+}
+
+func LoadDiffDetails(fileName string) ([]DiffDetail, error) {
+	//读取$table.diff.jsonl文件
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("LoadDiffDetails -> %w", err)
+	}
+	var details []DiffDetail
+	for i, line := range strings.Split(string(data), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		var d DiffDetail
+		if err := json.Unmarshal([]byte(line), &d); err != nil {
+			return nil, fmt.Errorf("LoadDiffDetails:%s:%d -> %w", fileName, i+1, err)
+		}
+		details = append(details, d)
+	}
+	return details, nil
+}
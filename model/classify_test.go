@@ -0,0 +1,47 @@
+package model
+
+import "testing"
+
+func TestClassifyDiff(t *testing.T) {
+	cases := []struct{ source, target, want string }{
+		{"abc", "NULL", "null"},
+		{"abc  ", "abc", "trailing_space"},
+		{" abc", "abc", "whitespace"},
+		{"ABC", "abc", "case"},
+		{`{"a":1,"b":[1,2]}`, `{"b": [1, 2], "a": 1}`, "json_format"},
+		{"2026-10-01 08:00:00", "2026-10-01 00:00:00", "time_offset:-8h"},
+		{"2026-10-01 00:00:00", "2026-10-01T08:00:00+08:00", "time_format"},
+		{"2026-10-01 00:00:00.123456", "2026-10-01 00:00:00.123", "time_precision"},
+		{"2026-10-01 00:00:00", "2026-10-02 00:30:00", "time_diff"},
+		{"10.50", "10.5", "numeric_format"},
+		{"3.14159", "3.14", "numeric_rounding"},
+		{"3.15", "3.14", "numeric_diff"},
+		{"hello world", "hello wor", "truncated"},
+		{"foo", "bar", "other"},
+		{"abc...(300 bytes)", "abc...(300 bytes)", "other"},
+	}
+	for _, c := range cases {
+		if got := ClassifyDiff(c.source, c.target); got != c.want {
+			t.Errorf("ClassifyDiff(%q, %q) = %s, want %s", c.source, c.target, got, c.want)
+		}
+	}
+}
+
+func TestAnalyzeDiffs(t *testing.T) {
+	var details []DiffDetail
+	for i := 0; i < 9; i++ {
+		details = append(details, DiffDetail{Columns: []ColumnDiff{{Column: "created_at", Source: "2026-10-01 00:00:00", Target: "2026-10-01 08:00:00"}}})
+	}
+	details = append(details, DiffDetail{Columns: []ColumnDiff{{Column: "created_at", Source: "2026-10-01 00:00:00", Target: "NULL"}, {Column: "amount", Source: "1.5", Target: "1.50"}}})
+
+	patterns := AnalyzeDiffs(details)
+	if len(patterns) != 3 {
+		t.Fatalf("AnalyzeDiffs = %v", patterns)
+	}
+	if p := patterns[1]; p.Column != "created_at" || p.Pattern != "time_offset:+8h" || p.Percent != 90 || p.Total != 10 {
+		t.Fatalf("patterns[1] = %+v", p)
+	}
+	if s := patterns[1].String(); s != "created_at: 90% 的不一致为 时间相差+8h(时区) (9/10)" {
+		t.Fatalf("String() = %s", s)
+	}
+}
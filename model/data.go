@@ -3,8 +3,8 @@ package model
 import "fmt"
 
 type Data struct {
-	Id  string
-	Sum uint32
+	Id  Key
+	Sum string
 }
 
 type TableInfo struct {
@@ -29,6 +29,9 @@ type Result struct {
 	TargetMoreRows  int
 	RecheckPassRows int
 	ExecuteSeconds  int
+	SchemaDiffs     []SchemaDiff    `json:",omitempty"` //schema模式下表结构的差异
+	Samples         []RowSample     `json:",omitempty"` //html报告中展示的不一致数据的样本
+	DiffPatterns    []ColumnPattern `json:",omitempty"` //复核后仍不一致的列按模式分类的统计
 }
 
 func (self *Result) GetLog() string {
@@ -0,0 +1,171 @@
+package model
+
+import (
+	"bytes"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"os"
+)
+
+/*
+Job 核对任务的配置文件，参数名和命令行参数一致，table-options下按表覆盖keys、where、skip-cols、chunk-size等参数，例如:
+
+	type: mysql
+	source: 10.0.0.201:3306
+	target: 10.0.0.202:3306
+	user: dba_ro
+	password: abc123
+	db: crm:crm01
+	parallel: 4
+	where: "updated_at < '2026-10-01'"
+	table-options:
+	  orders:
+	    keys: order_no
+	    source-where: "status <> 9"
+	    skip-cols: etl_time
+	    chunk-size: 200000
+*/
+type Job struct {
+	Type            string                  `yaml:"type"`
+	Source          string                  `yaml:"source"`
+	Target          string                  `yaml:"target"`
+	SourceDsn       string                  `yaml:"source-dsn"`
+	TargetDsn       string                  `yaml:"target-dsn"`
+	User            string                  `yaml:"user"`
+	Password        string                  `yaml:"password"`
+	TargetUser      string                  `yaml:"target-user"`
+	TargetPassword  string                  `yaml:"target-password"`
+	SourceType      string                  `yaml:"source-type"`
+	TargetType      string                  `yaml:"target-type"`
+	SourceSchema    string                  `yaml:"source-schema"`
+	TargetSchema    string                  `yaml:"target-schema"`
+	Mode            string                  `yaml:"mode"`
+	AlterDDL        bool                    `yaml:"alter-ddl"`
+	Html            bool                    `yaml:"html"`
+	HtmlSampleRows  int                     `yaml:"html-sample-rows"`
+	Hash            string                  `yaml:"hash"`
+	Db              string                  `yaml:"db"`
+	Tables          string                  `yaml:"tables"`
+	SkipTables      string                  `yaml:"skip-tables"`
+	Where           string                  `yaml:"where"`
+	SourceWhere     string                  `yaml:"source-where"`
+	TargetWhere     string                  `yaml:"target-where"`
+	Keys            string                  `yaml:"keys"`
+	SkipCols        string                  `yaml:"skip-cols"`
+	Parallel        int                     `yaml:"parallel"`
+	ChunkSize       int                     `yaml:"chunk-size"`
+	ChunkParallel   int                     `yaml:"chunk-parallel"`
+	ChunkChecksum   bool                    `yaml:"chunk-checksum"`
+	ChunkMinRows    int                     `yaml:"chunk-min-rows"`
+	MaxRecheckTimes int                     `yaml:"max-recheck-times"`
+	MaxRecheckRows  int                     `yaml:"max-recheck-rows"`
+	DocHash         string                  `yaml:"doc-hash"`
+	NumericTypes    string                  `yaml:"numeric-types"`
+	UnorderedArrays bool                    `yaml:"unordered-arrays"`
+	ServerDigest    bool                    `yaml:"server-digest"`
+	TableMap        string                  `yaml:"table-map"`
+	TablePattern    string                  `yaml:"table-pattern"`
+	TableReplace    string                  `yaml:"table-replace"`
+	ColumnMap       string                  `yaml:"column-map"`
+	Rules           string                  `yaml:"rules"`
+	TableOptions    map[string]*TableOption `yaml:"table-options"`
+}
+
+func LoadJob(fileName string) (*Options, error) {
+	//读取配置文件，未知的参数名报错，避免拼写错误的参数被忽略
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("LoadJob -> %w", err)
+	}
+	job := Job{Mode: "fast", Hash: "crc32", Parallel: 2, ChunkParallel: 2, ChunkMinRows: 1000, MaxRecheckTimes: 3, MaxRecheckRows: 1000, HtmlSampleRows: 20}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err = decoder.Decode(&job); err != nil {
+		return nil, fmt.Errorf("LoadJob:%s -> %w", fileName, err)
+	}
+	return job.Options()
+}
+
+func (self *Job) Options() (*Options, error) {
+	switch self.Type {
+	case "mysql", "doris", "oceanbase", "pgsql", "mssql", "mongo", "cross":
+	default:
+		return nil, fmt.Errorf("Job.Options:type参数无效:%s", self.Type)
+	}
+
+	opt := &Options{
+		DbType:          self.Type,
+		Source:          self.Source,
+		Target:          self.Target,
+		SourceDsn:       self.SourceDsn,
+		TargetDsn:       self.TargetDsn,
+		User:            self.User,
+		Password:        self.Password,
+		TargetUser:      self.TargetUser,
+		TargetPassword:  self.TargetPassword,
+		SourceType:      self.SourceType,
+		TargetType:      self.TargetType,
+		SourceSchema:    self.SourceSchema,
+		TargetSchema:    self.TargetSchema,
+		Mode:            self.Mode,
+		AlterDDL:        self.AlterDDL,
+		HtmlReport:      self.Html,
+		HtmlSampleRows:  self.HtmlSampleRows,
+		Hash:            self.Hash,
+		Db:              self.Db,
+		Tables:          self.Tables,
+		SkipTables:      self.SkipTables,
+		Where:           self.Where,
+		SourceWhere:     self.SourceWhere,
+		TargetWhere:     self.TargetWhere,
+		Keys:            self.Keys,
+		SkipCols:        self.SkipCols,
+		Parallel:        self.Parallel,
+		ChunkSize:       self.ChunkSize,
+		ChunkParallel:   self.ChunkParallel,
+		ChunkChecksum:   self.ChunkChecksum,
+		ChunkMinRows:    self.ChunkMinRows,
+		MaxRecheckTimes: self.MaxRecheckTimes,
+		MaxRecheckRows:  self.MaxRecheckRows,
+		DocHash:         self.DocHash,
+		NumericTypes:    self.NumericTypes,
+		UnorderedArrays: self.UnorderedArrays,
+		ServerDigest:    self.ServerDigest,
+		TableMap:        self.TableMap,
+		TablePattern:    self.TablePattern,
+		TableReplace:    self.TableReplace,
+		ColumnMap:       self.ColumnMap,
+		Rules:           self.Rules,
+		TableOptions:    self.TableOptions,
+	}
+	if (self.Type == "cross" || self.Type == "mongo") && self.Rules != "" {
+		return nil, fmt.Errorf("Job.Options:%s不支持rules参数", self.Type)
+	}
+	if self.Type == "cross" {
+		//跨数据库核对只支持slow和count模式，不拆分数据块
+		if self.Mode == "schema" {
+			return nil, fmt.Errorf("Job.Options:cross不支持schema模式")
+		}
+		if self.ChunkSize > 0 || self.ChunkChecksum {
+			return nil, fmt.Errorf("Job.Options:cross不支持chunk-size和chunk-checksum")
+		}
+	}
+	for name, t := range self.TableOptions {
+		if t == nil {
+			return nil, fmt.Errorf("Job.Options:表%s的参数为空", name)
+		}
+		if (self.Type == "cross" || self.Type == "mongo") && t.Rules != "" {
+			return nil, fmt.Errorf("Job.Options:表%s的rules参数无效，%s不支持规范化规则", name, self.Type)
+		}
+		if self.Type == "cross" && t.ChunkSize != nil && *t.ChunkSize > 0 {
+			return nil, fmt.Errorf("Job.Options:表%s的chunk-size参数无效，cross不支持拆分数据块", name)
+		}
+		if _, err := parseNameMap(t.ColumnMap); err != nil {
+			return nil, fmt.Errorf("Job.Options:表%s的column-map参数无效 -> %w", name, err)
+		}
+		if _, err := ParseRules(t.Rules); err != nil {
+			return nil, fmt.Errorf("Job.Options:表%s的rules参数无效 -> %w", name, err)
+		}
+	}
+	return opt, nil
+}
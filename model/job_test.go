@@ -0,0 +1,109 @@
+package model
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadJob(t *testing.T) {
+	text := `
+type: mysql
+source: 10.0.0.201:3306
+target: 10.0.0.202:3306
+user: dba_ro
+db: crm:crm01
+where: "updated_at < '2026-10-01'"
+chunk-size: 100000
+table-options:
+  orders:
+    keys: order_no,seq
+    target-where: "is_deleted = 0"
+    skip-cols: etl_time
+    chunk-size: 0
+  users_log:
+    source-where: "is_deleted = 0"
+  items:
+    source-where: "is_deleted = 0"
+`
+	fileName := filepath.Join(t.TempDir(), "job.yaml")
+	if err := os.WriteFile(fileName, []byte(text), 0644); err != nil {
+		t.Fatal(err)
+	}
+	opt, err := LoadJob(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opt.Init()
+
+	if opt.DbType != "mysql" || opt.Mode != "fast" || opt.MaxRecheckTimes != 3 || opt.BaseDir != "10.0.0.202_3306" {
+		t.Fatalf("unexpected options %+v", opt)
+	}
+	if users := opt.ForTable("users"); users != opt {
+		t.Fatalf("ForTable(users) should return the global options")
+	}
+
+	orders := opt.ForTable("orders")
+	if !reflect.DeepEqual(orders.KeysList, []string{"order_no", "seq"}) || !reflect.DeepEqual(orders.SkipColList, []string{"etl_time"}) {
+		t.Fatalf("unexpected table options %v %v", orders.KeysList, orders.SkipColList)
+	}
+	if orders.Where != opt.Where || orders.TargetWhere != "is_deleted = 0" || orders.ChunkSize != 0 || opt.ChunkSize != 100000 {
+		t.Fatalf("unexpected table options %q %q %d", orders.Where, orders.TargetWhere, orders.ChunkSize)
+	}
+
+	//只指定source-where时，目标端使用全局的where
+	log := opt.ForTable("users_log")
+	if log.SourceFilter() != "is_deleted = 0" || log.TargetFilter() != opt.Where {
+		t.Fatalf("unexpected filters %q %q", log.SourceFilter(), log.TargetFilter())
+	}
+	opt.Where = ""
+	if items := opt.ForTable("items"); items.SourceFilter() != "is_deleted = 0" || items.TargetFilter() != "" {
+		t.Fatalf("unexpected filters %q %q", items.SourceFilter(), items.TargetFilter())
+	}
+}
+
+func TestLoadJobUnknownField(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "job.yaml")
+	if err := os.WriteFile(fileName, []byte("type: mysql\nskip-col: a\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadJob(fileName); err == nil {
+		t.Fatal("expected an error for the unknown field skip-col")
+	}
+}
+
+func TestNameMap(t *testing.T) {
+	opt := Options{Source: "10.0.0.201:3306", Target: "10.0.0.202:3306", User: "dba_ro", Db: "crm",
+		TableMap: "users:t_users", TablePattern: "^", TableReplace: "ods_", ColumnMap: "id:user_id",
+		TableOptions: map[string]*TableOption{"orders": {ColumnMap: "no:order_no"}}}
+	opt.Init()
+
+	if tb := opt.TargetTable("users"); tb != "t_users" {
+		t.Fatalf("TargetTable(users) = %s", tb)
+	}
+	if tb := opt.TargetTable("orders"); tb != "ods_orders" {
+		t.Fatalf("TargetTable(orders) = %s", tb)
+	}
+	orders := opt.ForTable("orders")
+	if names := MapNames([]string{"id", "no", "amount"}, orders.ColumnMapList); !reflect.DeepEqual(names, []string{"user_id", "order_no", "amount"}) {
+		t.Fatalf("unexpected column names %v", names)
+	}
+	if _, ok := opt.ColumnMapList["no"]; ok {
+		t.Fatal("the table column-map should not change the global options")
+	}
+}
+
+func TestJobRulesUnsupported(t *testing.T) {
+	for _, job := range []Job{
+		{Type: "mongo", Rules: "amount:round(2)"},
+		{Type: "cross", TableOptions: map[string]*TableOption{"orders": {Rules: "name:rtrim"}}},
+	} {
+		if _, err := job.Options(); err == nil {
+			t.Errorf("%s: expected an error for rules", job.Type)
+		}
+	}
+	if _, err := (&Job{Type: "mysql", Rules: "amount:round(2)"}).Options(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,116 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+)
+
+/*
+Key 一行数据的主键值，多列主键按列的顺序编码成一个字符串，可以直接作为map的key，也是.diff/.tlost/.tmore文件中每一行的格式
+编码规则(同mysql load data的转义规则):
+值之间用,分隔
+值中的 \ , 回车 换行 分别转义为 \\ \, \r \n
+NULL 编码为 \N
+如 ('a,b', NULL, 'c') -> a\,b,\N,c
+单列主键且值中不包含特殊字符时，编码结果就是原值
+*/
+type Key string
+
+type KeyBuilder struct {
+	buf []byte
+	n   int
+}
+
+func (self *KeyBuilder) Reset() {
+	self.buf = self.buf[:0]
+	self.n = 0
+}
+
+func (self *KeyBuilder) Append(value []byte, isNull bool) {
+	if self.n > 0 {
+		self.buf = append(self.buf, ',')
+	}
+	self.n++
+
+	if isNull {
+		self.buf = append(self.buf, '\\', 'N')
+		return
+	}
+	for _, b := range value {
+		switch b {
+		case '\\', ',':
+			self.buf = append(self.buf, '\\', b)
+		case '\n':
+			self.buf = append(self.buf, '\\', 'n')
+		case '\r':
+			self.buf = append(self.buf, '\\', 'r')
+		default:
+			self.buf = append(self.buf, b)
+		}
+	}
+}
+
+func (self *KeyBuilder) Key() Key {
+	return Key(self.buf)
+}
+
+func NewKey(values []any) Key {
+	//values的元素为nil(NULL)或string
+	var kb KeyBuilder
+	for _, v := range values {
+		if v == nil {
+			kb.Append(nil, true)
+		} else {
+			kb.Append([]byte(v.(string)), false)
+		}
+	}
+	return kb.Key()
+}
+
+func (self Key) String() string {
+	return string(self)
+}
+
+func (self Key) Values() ([]any, error) {
+	//解码，NULL返回nil，其他值返回string
+	var values []any
+	var buf strings.Builder
+	isNull := false
+	for i := 0; i < len(self); i++ {
+		b := self[i]
+		switch b {
+		case '\\':
+			i++
+			if i >= len(self) {
+				return nil, fmt.Errorf("Key.Values:invalid key %s", string(self))
+			}
+			switch self[i] {
+			case 'N':
+				isNull = true
+			case 'n':
+				buf.WriteByte('\n')
+			case 'r':
+				buf.WriteByte('\r')
+			default:
+				buf.WriteByte(self[i])
+			}
+		case ',':
+			values = appendKeyValue(values, &buf, isNull)
+			isNull = false
+		default:
+			buf.WriteByte(b)
+		}
+	}
+	values = appendKeyValue(values, &buf, isNull)
+	return values, nil
+}
+
+func appendKeyValue(values []any, buf *strings.Builder, isNull bool) []any {
+	if isNull {
+		values = append(values, nil)
+	} else {
+		values = append(values, buf.String())
+	}
+	buf.Reset()
+	return values
+}
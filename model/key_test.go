@@ -0,0 +1,55 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestKey(t *testing.T) {
+	cases := []struct {
+		values []any
+		text   string
+	}{
+		{[]any{"1"}, `1`},
+		{[]any{"a,b", nil, "c"}, `a\,b,\N,c`},
+		{[]any{`\N`, ""}, `\\N,`},
+		{[]any{"", ""}, `,`},
+		{[]any{"line1\nline2\r"}, `line1\nline2\r`},
+		{[]any{nil}, `\N`},
+	}
+
+	for _, c := range cases {
+		key := NewKey(c.values)
+		if key.String() != c.text {
+			t.Fatalf("NewKey(%q) = %s, want %s", c.values, key, c.text)
+		}
+		values, err := key.Values()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(values, c.values) {
+			t.Fatalf("Key(%s).Values() = %q, want %q", key, values, c.values)
+		}
+	}
+
+	if _, err := Key(`a\`).Values(); err == nil {
+		t.Fatal("expected error for a trailing backslash")
+	}
+}
+
+func TestKeylessSeq(t *testing.T) {
+	var seq KeylessSeq
+	var kb KeyBuilder
+	var got []string
+	for _, row := range []string{"a", "a", "a", "b", "b", "c"} {
+		kb.Reset()
+		kb.Append([]byte("1"), false)
+		kb.Append([]byte(row), false)
+		seq.Append(&kb)
+		got = append(got, kb.Key().String())
+	}
+	want := []string{"1,a", "1,a,2", "1,a,3", "1,b", "1,b,2", "1,c"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
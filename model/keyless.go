@@ -0,0 +1,69 @@
+package model
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+)
+
+// KeylessSeq 没有主键的表按整行数据排序后，相同的行相邻，只需要记住上一行就可以得到出现的序号
+type KeylessSeq struct {
+	last Key
+	seq  int
+}
+
+func (self *KeylessSeq) Append(kb *KeyBuilder) {
+	//kb为一行数据的编码，第2次及以后出现时在最后加上序号，如 (1,'a') 出现3次 -> 1,a  1,a,2  1,a,3
+	key := kb.Key()
+	if self.seq > 0 && key == self.last {
+		self.seq++
+		kb.Append([]byte(strconv.Itoa(self.seq)), false)
+		return
+	}
+	self.last, self.seq = key, 1
+}
+
+func PullKeylessRows(conn *sql.DB, sqlText string, columns int, dataCh chan<- *Data, doneCh <-chan struct{}, rows *int) (stopped bool, err error) {
+	//读取按所有列排序的查询结果，每行数据加上出现的序号后作为Id，收到停止信号时stopped为true
+	cur, err := conn.Query(sqlText)
+	if err != nil {
+		return false, fmt.Errorf("PullKeylessRows:Query -> %w", err)
+	}
+	defer cur.Close()
+
+	values := make([]*sql.RawBytes, columns)
+	valuesP := make([]interface{}, len(values))
+	for i := range values {
+		valuesP[i] = &values[i]
+	}
+
+	var kb KeyBuilder
+	var seq KeylessSeq
+	for cur.Next() {
+		if err := cur.Scan(valuesP...); err != nil {
+			return false, fmt.Errorf("PullKeylessRows:Scan -> %w", err)
+		}
+
+		kb.Reset()
+		for i := range values {
+			if values[i] == nil {
+				kb.Append(nil, true)
+			} else {
+				kb.Append(*values[i], false)
+			}
+		}
+		seq.Append(&kb)
+
+		data := Data{Id: kb.Key()}
+		select {
+		case dataCh <- &data:
+			*rows++
+		case <-doneCh:
+			return true, nil
+		}
+	}
+	if err := cur.Err(); err != nil {
+		return false, fmt.Errorf("PullKeylessRows -> %w", err)
+	}
+	return false, nil
+}
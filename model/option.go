@@ -1,8 +1,10 @@
 package model
 
 import (
+    "checkData/util"
     "fmt"
     "os"
+    "regexp"
     "strconv"
     "strings"
 )
@@ -14,8 +16,16 @@ type Options struct {
     Password        string
     TargetUser      string
     TargetPassword  string
+    SourceDsn       string //源端的连接串，指定后不再使用Source/User/Password连接源端
+    TargetDsn       string //目标端的连接串
     DbType          string
-    Mode            string
+    SourceType      string //跨数据库核对时源端的数据库类型
+    TargetType      string //跨数据库核对时目标端的数据库类型
+    SourceSchema    string
+    TargetSchema    string
+    Mode            string //fast,slow,count,schema
+    AlterDDL        bool   //schema模式下生成使目标端和源端一致的DDL
+    Hash            string //行数据的摘要算法:crc32,xxhash64,md5,sha256
     Db              string
     Tables          string
     SkipCols        string
@@ -25,6 +35,8 @@ type Options struct {
     TargetHost      string
     TargetPort      int
     Where           string
+    SourceWhere     string //源端的过滤条件，为空时和Where相同
+    TargetWhere     string //目标端的过滤条件，为空时和Where相同
     Keys            string
     DbList          []string
     DbGroupList     [][2]string
@@ -33,10 +45,50 @@ type Options struct {
     SkipTableList   []string
     KeysList        []string
     Parallel        int
+    ChunkSize       int //每个数据块的行数，0表示不拆分
+    ChunkParallel   int //单个表核对数据块的并行数
+    ChunkChecksum   bool //先对比数据块的聚合校验和，只核对不一致的数据块
+    ChunkMinRows    int  //数据块小于这个行数时不再二分，直接逐行核对
     MaxRecheckTimes int
     MaxRecheckRows  int
     Capacity        int //不一致的行数超过这个数，直接退出
     BaseDir         string
+    Resume          bool //断点续核，跳过断点文件中已完成的表和数据块
+    HtmlReport      bool //生成html报告
+    HtmlSampleRows  int  //html报告中每个表展示的不一致数据的行数
+    WatchLag        int  //持续核对时，变更后等待多少秒再复核(复制延迟)
+    WatchInterval   int  //持续核对时，每隔多少秒复核一次
+    Mysqlbinlog     string
+    BinlogFile      string //开始读取的binlog文件，为空时从当前位置开始
+    BinlogPos       int
+    Slot            string //pgsql逻辑复制槽
+    Plugin          string //pgsql逻辑解码插件:test_decoding,wal2json
+    DocHash         string //mongo文档摘要的计算方式:raw,canonical
+    NumericTypes    string //canonical时数值类型的比较方式:loose,strict
+    UnorderedArrays bool   //canonical时忽略数组元素的顺序
+    ServerDigest    bool   //mongo的fast模式在服务端使用js计算文档JSON的摘要，不区分数值、ObjectId和字符串等类型
+    TableOptions    map[string]*TableOption //配置文件中按表指定的参数
+    TableMap        string //表名映射，如 a:ods_a,b:ods_b
+    TablePattern    string //表名改写的正则表达式，和TableReplace一起使用，如 ^ -> ods_
+    TableReplace    string
+    ColumnMap       string //列名映射，如 a:a1,b:b1
+    Rules           string //列的规范化规则，如 amount:round(2),name:rtrim|lower
+    TableMapList    map[string]string
+    ColumnMapList   map[string]string
+    RuleList        map[string]*ColumnRule //源端列名 -> 规范化规则
+    tableRegexp     *regexp.Regexp
+}
+
+// TableOption 配置文件中单个表的参数，覆盖全局的同名参数，格式和命令行参数一致
+type TableOption struct {
+    Keys        string `yaml:"keys"`
+    Where       string `yaml:"where"`
+    SourceWhere string `yaml:"source-where"`
+    TargetWhere string `yaml:"target-where"`
+    SkipCols    string `yaml:"skip-cols"`
+    ChunkSize   *int   `yaml:"chunk-size"` //0表示这个表不拆分
+    ColumnMap   string `yaml:"column-map"` //和全局的列名映射合并
+    Rules       string `yaml:"rules"`      //和全局的规范化规则合并，同一列以表的规则为准
 }
 
 func (self *Options) Init() {
@@ -56,15 +108,28 @@ func (self *Options) Init() {
         port, _ := strconv.ParseUint(t[1], 10, 64)
         self.TargetPort = int(port)
     }
-    if self.SourcePort == 0 || self.TargetPort == 0 {
+    if self.SourcePort == 0 && self.SourceDsn == "" || self.TargetPort == 0 && self.TargetDsn == "" {
         fmt.Println("source或target参数无效")
         os.Exit(1)
     }
 
-    self.BaseDir = fmt.Sprintf("%s_%d", self.TargetHost, self.TargetPort)
+    if self.TargetPort != 0 {
+        self.BaseDir = fmt.Sprintf("%s_%d", self.TargetHost, self.TargetPort)
+    } else {
+        //使用连接串中的地址命名结果目录
+        host, port := util.DsnAddr(self.TargetDsn)
+        if host == "" {
+            fmt.Println("target-dsn参数无效:", self.TargetDsn)
+            os.Exit(1)
+        }
+        self.BaseDir = host
+        if port != "" {
+            self.BaseDir = fmt.Sprintf("%s_%s", host, port)
+        }
+    }
 
-    //用户账号
-    if self.User == "" {
+    //用户账号，使用连接串时账号在连接串中
+    if self.User == "" && (self.SourceDsn == "" || self.TargetDsn == "" && self.TargetUser == "") {
         fmt.Println("用户名不能为空")
         os.Exit(1)
     }
@@ -127,9 +192,200 @@ func (self *Options) Init() {
         self.Parallel = 1
     }
 
+    //摘要算法
+    if self.Hash == "" {
+        self.Hash = "crc32"
+    }
+
+    //单表并行数
+    if self.ChunkParallel <= 0 {
+        self.ChunkParallel = 1
+    }
+
+    //数据块校验和
+    if self.ChunkChecksum && self.ChunkSize == 0 {
+        self.ChunkSize = 100000
+    }
+    if self.ChunkMinRows <= 0 {
+        self.ChunkMinRows = 1000
+    }
+
+    //持续核对
+    if self.WatchLag <= 0 {
+        self.WatchLag = 60
+    }
+    if self.WatchInterval <= 0 {
+        self.WatchInterval = 10
+    }
+    if self.Mysqlbinlog == "" {
+        self.Mysqlbinlog = "mysqlbinlog"
+    }
+    if self.Slot == "" {
+        self.Slot = "checkdata"
+    }
+    if self.Plugin == "" {
+        self.Plugin = "test_decoding"
+    }
+
+    //mongo文档摘要
+    switch {
+    case self.DocHash != "" && self.DocHash != "raw" && self.DocHash != "canonical":
+        fmt.Println("doc-hash参数无效:", self.DocHash)
+        os.Exit(1)
+    case self.NumericTypes != "" && self.NumericTypes != "loose" && self.NumericTypes != "strict":
+        fmt.Println("numeric-types参数无效:", self.NumericTypes)
+        os.Exit(1)
+    }
+
+    //表名和列名映射
+    var err error
+    self.TableMapList, err = parseNameMap(self.TableMap)
+    if err != nil {
+        fmt.Println("table-map参数无效:", self.TableMap)
+        os.Exit(1)
+    }
+    self.ColumnMapList, err = parseNameMap(self.ColumnMap)
+    if err != nil {
+        fmt.Println("column-map参数无效:", self.ColumnMap)
+        os.Exit(1)
+    }
+    self.RuleList, err = ParseRules(self.Rules)
+    if err != nil {
+        fmt.Println("rules参数无效:", err)
+        os.Exit(1)
+    }
+    if self.TablePattern != "" {
+        self.tableRegexp, err = regexp.Compile(self.TablePattern)
+        if err != nil {
+            fmt.Println("table-pattern参数无效:", err)
+            os.Exit(1)
+        }
+    }
+
     //容量
     if self.Capacity == 0 {
         self.Capacity = 10000
     }
 
 }
+
+func (self *Options) ForTable(tbName string) *Options {
+    //返回合并了表参数的Options，没有表参数时返回自身
+    t, ok := self.TableOptions[tbName]
+    if !ok {
+        return self
+    }
+
+    opt := *self
+    if t.Keys != "" {
+        opt.KeysList = strings.Split(t.Keys, ",")
+    }
+    if t.SkipCols != "" {
+        opt.SkipColList = strings.Split(t.SkipCols, ",")
+    }
+    if t.Where != "" {
+        opt.Where = t.Where
+        opt.SourceWhere = ""
+        opt.TargetWhere = ""
+    }
+    if t.SourceWhere != "" {
+        opt.SourceWhere = t.SourceWhere
+    }
+    if t.TargetWhere != "" {
+        opt.TargetWhere = t.TargetWhere
+    }
+    if t.ChunkSize != nil {
+        opt.ChunkSize = *t.ChunkSize
+    }
+    if t.ColumnMap != "" {
+        columnMap, _ := parseNameMap(t.ColumnMap)
+        opt.ColumnMapList = make(map[string]string, len(self.ColumnMapList)+len(columnMap))
+        for k, v := range self.ColumnMapList {
+            opt.ColumnMapList[k] = v
+        }
+        for k, v := range columnMap {
+            opt.ColumnMapList[k] = v
+        }
+    }
+    if t.Rules != "" {
+        rules, _ := ParseRules(t.Rules)
+        opt.RuleList = make(map[string]*ColumnRule, len(self.RuleList)+len(rules))
+        for k, v := range self.RuleList {
+            opt.RuleList[k] = v
+        }
+        for k, v := range rules {
+            opt.RuleList[k] = v
+        }
+    }
+    return &opt
+}
+
+func (self *Options) SourceFilter() string {
+    //源端的过滤条件
+    if self.SourceWhere != "" {
+        return self.SourceWhere
+    }
+    return self.Where
+}
+
+func (self *Options) TargetFilter() string {
+    //目标端的过滤条件，只指定了source-where时目标端仍使用where
+    if self.TargetWhere != "" {
+        return self.TargetWhere
+    }
+    return self.Where
+}
+
+func (self *Options) TargetTable(tbName string) string {
+    //源端表名 -> 目标端表名，先使用table-map，再使用table-pattern改写
+    if name, ok := self.TableMapList[tbName]; ok {
+        return name
+    }
+    if self.tableRegexp != nil {
+        return self.tableRegexp.ReplaceAllString(tbName, self.TableReplace)
+    }
+    return tbName
+}
+
+func (self *Options) Masked() *Options {
+    //隐藏密码，用于写入报告
+    opt := *self
+    if opt.Password != "" {
+        opt.Password = "******"
+    }
+    if opt.TargetPassword != "" {
+        opt.TargetPassword = "******"
+    }
+    opt.SourceDsn = util.MaskDsn(opt.SourceDsn)
+    opt.TargetDsn = util.MaskDsn(opt.TargetDsn)
+    return &opt
+}
+
+func MapNames(names []string, m map[string]string) []string {
+    //按映射关系替换名称，没有映射的名称保持不变
+    list := make([]string, 0, len(names))
+    for _, n := range names {
+        if name, ok := m[n]; ok {
+            list = append(list, name)
+        } else {
+            list = append(list, n)
+        }
+    }
+    return list
+}
+
+func parseNameMap(text string) (map[string]string, error) {
+    // a:b,c:d -> {a: b, c: d}
+    m := make(map[string]string)
+    if text == "" {
+        return m, nil
+    }
+    for _, item := range strings.Split(text, ",") {
+        k, v, ok := strings.Cut(item, ":")
+        if !ok || k == "" || v == "" {
+            return nil, fmt.Errorf("parseNameMap:invalid item %s", item)
+        }
+        m[k] = v
+    }
+    return m, nil
+}
@@ -0,0 +1,27 @@
+package model
+
+import "time"
+
+// Version 写入json报告的程序版本，和checkData.go中的版本记录一致
+const Version = "v2.9.0"
+
+// Report 一个数据库的json报告，保存在 <BaseDir>/<db>.json，字段名和csv文件的列名一致
+type Report struct {
+	Version      string
+	StartTime    time.Time
+	EndTime      time.Time
+	SourceDb     string
+	TargetDb     string
+	Options      *Options //隐藏了密码
+	Tables       *TableInfo
+	SameTables   []string
+	DiffTables   []string
+	FailedTables []string
+	Results      []*TableReport
+}
+
+// TableReport 一个表的核对结果和结果文件，也是 <BaseDir>/<db>.jsonl 中每一行的格式
+type TableReport struct {
+	*Result
+	Files map[string]string `json:",omitempty"` //diff,diff_detail,tlost,tmore,insert,update,delete,alter -> 文件路径
+}
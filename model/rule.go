@@ -0,0 +1,260 @@
+package model
+
+import (
+	"checkData/util"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+ColumnRule 一列的规范化规则，两端的值规范化后再比较，用于目标端存储的是舍入、截断、去空格后的数据:
+
+	round(N)    数值按四舍五入(远离0)保留N位小数，结果固定N位小数
+	trunc(unit) 时间截断到second,minute,hour,day，结果格式为 2006-01-02 15:04:05
+	shift(+8h)  源端的时间加上时长后再比较(时区)，目标端不变，没有指定trunc时截断到秒
+	rtrim       去掉末尾的空格
+	lower       转为小写
+	empty_null  空字符串等同于NULL
+
+一列可以指定多个规则，用|分隔，按 rtrim,lower,empty_null,shift,trunc,round 的顺序执行
+*/
+type ColumnRule struct {
+	Round     int           //保留的小数位数，-1表示不舍入
+	Trunc     string        //second,minute,hour,day
+	Shift     time.Duration //只作用于源端
+	Rtrim     bool
+	Lower     bool
+	EmptyNull bool
+}
+
+// 截断后保留的长度和补齐的后缀，结果和数据库侧的表达式一致
+var truncUnits = map[string]struct {
+	size   int
+	suffix string
+}{
+	"second": {19, ""},
+	"minute": {16, ":00"},
+	"hour":   {13, ":00:00"},
+	"day":    {10, " 00:00:00"},
+}
+
+func TruncLayout(unit string) (int, string) {
+	u := truncUnits[unit]
+	return u.size, u.suffix
+}
+
+func ParseRules(text string) (map[string]*ColumnRule, error) {
+	// amount:round(2),name:rtrim|lower -> {amount: round(2), name: rtrim|lower}
+	m := make(map[string]*ColumnRule)
+	if text == "" {
+		return m, nil
+	}
+	for _, item := range strings.Split(text, ",") {
+		col, rules, ok := strings.Cut(item, ":")
+		col, rules = strings.TrimSpace(col), strings.TrimSpace(rules)
+		if !ok || col == "" || rules == "" {
+			return nil, fmt.Errorf("ParseRules:invalid item %s", item)
+		}
+		rule, err := parseColumnRule(rules)
+		if err != nil {
+			return nil, fmt.Errorf("ParseRules:%s -> %w", col, err)
+		}
+		m[col] = rule
+	}
+	return m, nil
+}
+
+func parseColumnRule(text string) (*ColumnRule, error) {
+	rule := &ColumnRule{Round: -1}
+	for _, r := range strings.Split(text, "|") {
+		name, arg, _ := strings.Cut(strings.TrimSpace(r), "(")
+		if arg != "" {
+			if !strings.HasSuffix(arg, ")") {
+				return nil, fmt.Errorf("parseColumnRule:invalid rule %s", r)
+			}
+			arg = strings.TrimSpace(strings.TrimSuffix(arg, ")"))
+		}
+		switch name {
+		case "round":
+			n, err := strconv.Atoi(arg)
+			if err != nil || n < 0 || n > 30 {
+				return nil, fmt.Errorf("parseColumnRule:invalid rule %s, the digits must be 0-30", r)
+			}
+			rule.Round = n
+		case "trunc":
+			if _, ok := truncUnits[arg]; !ok {
+				return nil, fmt.Errorf("parseColumnRule:invalid rule %s, the unit must be second,minute,hour,day", r)
+			}
+			rule.Trunc = arg
+		case "shift":
+			d, err := time.ParseDuration(arg)
+			if err != nil || d%time.Second != 0 {
+				return nil, fmt.Errorf("parseColumnRule:invalid rule %s, e.g., shift(+8h)", r)
+			}
+			rule.Shift = d
+		case "rtrim":
+			rule.Rtrim = true
+		case "lower":
+			rule.Lower = true
+		case "empty_null":
+			rule.EmptyNull = true
+		default:
+			return nil, fmt.Errorf("parseColumnRule:unknown rule %s", r)
+		}
+	}
+	if rule.Shift != 0 && rule.Trunc == "" {
+		rule.Trunc = "second"
+	}
+	return rule, nil
+}
+
+func (self *ColumnRule) String() string {
+	var list []string
+	if self.Rtrim {
+		list = append(list, "rtrim")
+	}
+	if self.Lower {
+		list = append(list, "lower")
+	}
+	if self.EmptyNull {
+		list = append(list, "empty_null")
+	}
+	if self.Shift != 0 {
+		list = append(list, fmt.Sprintf("shift(%+ds)", int64(self.Shift/time.Second)))
+	}
+	if self.Trunc != "" {
+		list = append(list, fmt.Sprintf("trunc(%s)", self.Trunc))
+	}
+	if self.Round >= 0 {
+		list = append(list, fmt.Sprintf("round(%d)", self.Round))
+	}
+	return strings.Join(list, "|")
+}
+
+func (self *ColumnRule) ForTarget() *ColumnRule {
+	//目标端的规则，不做时间偏移
+	rule := *self
+	rule.Shift = 0
+	return &rule
+}
+
+func (self *ColumnRule) Apply(value string, isNull bool) (string, bool) {
+	//规范化一个值，无法解析为时间或数值的值保持不变
+	if isNull {
+		return value, true
+	}
+	if self.Rtrim {
+		value = strings.TrimRight(value, " ")
+	}
+	if self.Lower {
+		value = strings.ToLower(value)
+	}
+	if self.EmptyNull && value == "" {
+		return "", true
+	}
+	if self.Trunc != "" {
+		if t, ok := parseDiffTime(value); ok {
+			size, suffix := TruncLayout(self.Trunc)
+			value = t.Add(self.Shift).Format("2006-01-02 15:04:05")[:size] + suffix
+		}
+	}
+	if self.Round >= 0 {
+		if v, ok := roundDecimal(value, self.Round); ok {
+			value = v
+		}
+	}
+	return value, false
+}
+
+func (self *ColumnRule) AppendValue(buf []byte, value []byte, isNull bool) []byte {
+	//本地计算摘要时拼接规范化后的值，没有规则时和util.AppendEncodedValue相同
+	if self == nil {
+		return util.AppendEncodedValue(buf, value, isNull)
+	}
+	v, isNull := self.Apply(string(value), isNull)
+	return util.AppendEncodedValue(buf, []byte(v), isNull)
+}
+
+func RuleList(columns []string, rules map[string]*ColumnRule) []*ColumnRule {
+	//和columns按位置对应的规则，没有规则的列为nil
+	list := make([]*ColumnRule, len(columns))
+	for i, c := range columns {
+		list[i] = rules[c]
+	}
+	return list
+}
+
+func NormalizeRows(rows []map[string]string, columns []string, rules []*ColumnRule) {
+	//复核时规范化查询结果，util.QueryReturnDict使用NULL表示空值
+	for _, row := range rows {
+		for i, c := range columns {
+			if rules[i] == nil {
+				continue
+			}
+			if v, isNull := rules[i].Apply(row[c], row[c] == "NULL"); isNull {
+				row[c] = "NULL"
+			} else {
+				row[c] = v
+			}
+		}
+	}
+}
+
+func roundDecimal(v string, n int) (string, bool) {
+	//按十进制字符串舍入，避免浮点数误差，2.675保留2位为2.68
+	s := strings.TrimSpace(v)
+	if strings.ContainsAny(s, "eE") {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+			return v, false
+		}
+		s = strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	neg := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "-"), "+")
+	intPart, frac, _ := strings.Cut(s, ".")
+	if intPart == "" && frac == "" || !isDigits(intPart) || !isDigits(frac) {
+		return v, false
+	}
+	for len(frac) <= n {
+		frac += "0"
+	}
+
+	digits := []byte(intPart + frac[:n])
+	if frac[n] >= '5' {
+		i := len(digits) - 1
+		for ; i >= 0 && digits[i] == '9'; i-- {
+			digits[i] = '0'
+		}
+		if i >= 0 {
+			digits[i]++
+		} else {
+			digits = append([]byte{'1'}, digits...)
+		}
+	}
+
+	size := len(digits) - n
+	res := strings.TrimLeft(string(digits[:size]), "0")
+	if res == "" {
+		res = "0"
+	}
+	if n > 0 {
+		res += "." + string(digits[size:])
+	}
+	if neg && strings.Trim(res, "0.") != "" {
+		res = "-" + res
+	}
+	return res, true
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
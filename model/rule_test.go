@@ -0,0 +1,76 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRules(t *testing.T) {
+	rules, err := ParseRules("amount:round(2),created_at:shift(+8h),name:rtrim|lower,memo:empty_null")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := rules["amount"]; r.Round != 2 || r.Trunc != "" {
+		t.Fatalf("amount = %+v", r)
+	}
+	if r := rules["created_at"]; r.Shift != 8*time.Hour || r.Trunc != "second" || r.ForTarget().Shift != 0 {
+		t.Fatalf("created_at = %+v", r)
+	}
+	if r := rules["name"]; r.String() != "rtrim|lower" || r.Round != -1 {
+		t.Fatalf("name = %s", r)
+	}
+	for _, text := range []string{"amount", "amount:round(x)", "ts:trunc(week)", "ts:shift(8)", "name:upper"} {
+		if _, err := ParseRules(text); err == nil {
+			t.Errorf("ParseRules(%s) expected an error", text)
+		}
+	}
+}
+
+func TestColumnRuleApply(t *testing.T) {
+	cases := []struct {
+		rule, value string
+		isNull      bool
+		want        string
+		wantNull    bool
+	}{
+		{"round(2)", "2.675", false, "2.68", false},
+		{"round(2)", "-2.675", false, "-2.68", false},
+		{"round(2)", "3", false, "3.00", false},
+		{"round(0)", "9.5", false, "10", false},
+		{"round(1)", "-0.04", false, "0.0", false},
+		{"round(2)", "1.5e-3", false, "0.00", false},
+		{"round(2)", "abc", false, "abc", false},
+		{"trunc(second)", "2026-10-18 12:34:56.789", false, "2026-10-18 12:34:56", false},
+		{"trunc(day)", "2026-10-18 12:34:56", false, "2026-10-18 00:00:00", false},
+		{"trunc(hour)", "2026-10-18", false, "2026-10-18 00:00:00", false},
+		{"shift(+8h)", "2026-10-18 20:00:00.5", false, "2026-10-19 04:00:00", false},
+		{"shift(-30m)|trunc(minute)", "2026-10-18 00:10:20+08", false, "2026-10-17 23:40:00", false},
+		{"rtrim|lower", "Abc  ", false, "abc", false},
+		{"rtrim|empty_null", "   ", false, "", true},
+		{"empty_null", "", true, "", true},
+	}
+	for _, c := range cases {
+		rule, err := parseColumnRule(c.rule)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, isNull := rule.Apply(c.value, c.isNull); got != c.want || isNull != c.wantNull {
+			t.Errorf("%s.Apply(%q) = %q, %v, want %q, %v", c.rule, c.value, got, isNull, c.want, c.wantNull)
+		}
+	}
+}
+
+func TestNormalizeRows(t *testing.T) {
+	rules, _ := ParseRules("memo:empty_null,amount:round(1)")
+	columns := []string{"name", "amount", "memo"}
+	rows := []map[string]string{{"name": "a ", "amount": "1.25", "memo": ""}}
+	NormalizeRows(rows, columns, RuleList(columns, rules))
+	if rows[0]["name"] != "a " || rows[0]["amount"] != "1.3" || rows[0]["memo"] != "NULL" {
+		t.Fatalf("rows = %v", rows)
+	}
+
+	var rule *ColumnRule
+	if buf := rule.AppendValue(nil, []byte("a"), false); string(buf) != "1:a" {
+		t.Fatalf("AppendValue = %s", buf)
+	}
+}
@@ -0,0 +1,96 @@
+package model
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// RowSample 不一致数据的样本，按主键查询的两端的一行数据，用于html报告展示不一致的列，行不存在时为nil
+type RowSample struct {
+	Id          Key
+	Kind        string            //diff,tlost,tmore
+	Columns     []string          `json:",omitempty"` //列的顺序
+	Source      map[string]string `json:",omitempty"`
+	Target      map[string]string `json:",omitempty"`
+	DiffColumns []string          `json:",omitempty"` //值不同的列，只有一端有数据时为空
+	Error       string            `json:",omitempty"` //查询报错的信息
+}
+
+func NewRowSample(id Key, kind string, columns []string, source, target map[string]string) RowSample {
+	sample := RowSample{Id: id, Kind: kind, Columns: columns, Source: source, Target: target}
+	if source == nil || target == nil {
+		return sample
+	}
+	for _, c := range columns {
+		s, ok1 := source[c]
+		t, ok2 := target[c]
+		if ok1 != ok2 || s != t {
+			sample.DiffColumns = append(sample.DiffColumns, c)
+		}
+	}
+	return sample
+}
+
+func (self RowSample) IsDiff(column string) bool {
+	for _, c := range self.DiffColumns {
+		if c == column {
+			return true
+		}
+	}
+	return false
+}
+
+// MaxDiffValueLen 列级差异中保存的值的最大字节数，超过时截断
+const MaxDiffValueLen = 256
+
+// DiffDetail 一行不一致数据的列级差异，$table.diff.jsonl中每行一个
+type DiffDetail struct {
+	Id      Key
+	Columns []ColumnDiff `json:",omitempty"`
+	Error   string       `json:",omitempty"` //查询报错，或者复核后一端已经没有这行数据
+}
+
+// ColumnDiff 一列两端的值，NULL为"NULL"，一端没有这一列时为"(无此列)"
+type ColumnDiff struct {
+	Column  string
+	Source  string
+	Target  string
+	Pattern string `json:",omitempty"` //按截断前的值分类，见ClassifyDiff
+}
+
+func NewDiffDetail(sample RowSample) DiffDetail {
+	detail := DiffDetail{Id: sample.Id, Error: sample.Error}
+	switch {
+	case sample.Error != "":
+	case sample.Source == nil:
+		detail.Error = "Source端没有这行数据"
+	case sample.Target == nil:
+		detail.Error = "Target端没有这行数据"
+	}
+	for _, c := range sample.DiffColumns {
+		//截断后的值可能相同或者丢失了差异，按完整的值分类
+		source, target := columnValue(sample.Source, c), columnValue(sample.Target, c)
+		detail.Columns = append(detail.Columns, ColumnDiff{Column: c, Source: truncValue(source), Target: truncValue(target), Pattern: ClassifyDiff(source, target)})
+	}
+	return detail
+}
+
+func columnValue(row map[string]string, column string) string {
+	v, ok := row[column]
+	if !ok {
+		return "(无此列)"
+	}
+	return v
+}
+
+func truncValue(v string) string {
+	if len(v) <= MaxDiffValueLen {
+		return v
+	}
+	//按utf8字符截断
+	n := MaxDiffValueLen
+	for n > 0 && !utf8.RuneStart(v[n]) {
+		n--
+	}
+	return fmt.Sprintf("%s...(%d bytes)", v[:n], len(v))
+}
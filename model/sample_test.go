@@ -0,0 +1,46 @@
+package model
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestNewRowSample(t *testing.T) {
+	columns := []string{"name", "amount", "memo"}
+	source := map[string]string{"name": "a", "amount": "1.00", "memo": ""}
+	target := map[string]string{"name": "a", "amount": "1.10"}
+	sample := NewRowSample("1", "diff", columns, source, target)
+	if !reflect.DeepEqual(sample.DiffColumns, []string{"amount", "memo"}) {
+		t.Fatalf("DiffColumns = %v", sample.DiffColumns)
+	}
+	if sample = NewRowSample("2", "tlost", columns, source, nil); sample.DiffColumns != nil {
+		t.Fatalf("DiffColumns = %v, expected nil", sample.DiffColumns)
+	}
+}
+
+func TestNewDiffDetail(t *testing.T) {
+	long := strings.Repeat("中", 100)
+	source := map[string]string{"memo": long, "amount": "1.00"}
+	target := map[string]string{"memo": long + "x", "amount": "1.00"}
+	detail := NewDiffDetail(NewRowSample("1", "diff", []string{"amount", "memo"}, source, target))
+	if len(detail.Columns) != 1 || detail.Columns[0].Column != "memo" {
+		t.Fatalf("Columns = %+v", detail.Columns)
+	}
+	if v := detail.Columns[0].Source; !utf8.ValidString(v) || !strings.HasSuffix(v, "...(300 bytes)") || len(v) > MaxDiffValueLen+20 {
+		t.Fatalf("Source = %s", v)
+	}
+	if p := detail.Columns[0].Pattern; p != "truncated" {
+		t.Fatalf("Pattern = %s", p)
+	}
+
+	//截断后相同的值按完整的值分类
+	source["memo"], target["memo"] = long+" ", long
+	if detail = NewDiffDetail(NewRowSample("1", "diff", []string{"memo"}, source, target)); detail.Columns[0].Pattern != "trailing_space" {
+		t.Fatalf("Columns = %+v", detail.Columns)
+	}
+	if detail = NewDiffDetail(NewRowSample("2", "diff", []string{"amount"}, source, nil)); detail.Error == "" {
+		t.Fatalf("expected an error for the missing target row")
+	}
+}
@@ -0,0 +1,249 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ColumnDef 列定义，Type使用数据库返回的类型名，如 varchar(20)、numeric(10,2)
+type ColumnDef struct {
+	Name     string
+	Type     string
+	Nullable bool
+	Default  *string //nil表示没有默认值
+	Charset  string  //字符集和排序规则，如 CHARACTER SET utf8mb4 COLLATE utf8mb4_bin，DDL中放在类型后面
+	Extra    string  //默认值后面的其他属性，如 AUTO_INCREMENT、ON UPDATE CURRENT_TIMESTAMP
+	Comment  string
+	Manual   bool //有无法按以上属性还原的定义(如生成列)，修改这一列的DDL被注释，需要人工确认
+}
+
+// IndexDef 索引定义，主键也是一个Primary为true的索引；mongo的Columns为 字段:方向，如 a:1
+type IndexDef struct {
+	Name    string
+	Columns []string
+	Unique  bool
+	Primary bool
+}
+
+// TableDef 表结构，Options保存其他需要核对的属性，如mongo的validator
+type TableDef struct {
+	Columns []ColumnDef
+	Indexes []IndexDef
+	Options map[string]string
+}
+
+// SchemaDiff 表结构的一处差异，Column和Index用于生成DDL
+type SchemaDiff struct {
+	Kind   string     //column_missing,column_extra,column_type,column_nullable,column_default,primary_key,index_missing,index_extra,option
+	Name   string     //目标端的列名、索引名或属性名，primary_key时为目标端主键的名称
+	Source string     //源端的定义
+	Target string     //目标端的定义
+	Column *ColumnDef `json:",omitempty"` //源端的列定义(列名已映射成目标端的列名)，column_extra时为目标端的列定义
+	Index  *IndexDef  `json:",omitempty"` //源端的索引定义(列名已映射)，index_extra时为目标端的索引定义，primary_key时为源端的主键
+}
+
+func (self SchemaDiff) String() string {
+	switch self.Kind {
+	case "column_missing":
+		return fmt.Sprintf("目标端缺少列 %s %s", self.Name, self.Source)
+	case "column_extra":
+		return fmt.Sprintf("目标端多出列 %s %s", self.Name, self.Target)
+	case "column_type":
+		return fmt.Sprintf("列类型不一致 %s: %s -> %s", self.Name, self.Source, self.Target)
+	case "column_nullable":
+		return fmt.Sprintf("列是否可空不一致 %s: %s -> %s", self.Name, self.Source, self.Target)
+	case "column_default":
+		return fmt.Sprintf("列默认值不一致 %s: %s -> %s", self.Name, self.Source, self.Target)
+	case "primary_key":
+		return fmt.Sprintf("主键不一致: (%s) -> (%s)", self.Source, self.Target)
+	case "index_missing":
+		return fmt.Sprintf("目标端缺少索引 %s (%s)", self.Name, self.Source)
+	case "index_extra":
+		return fmt.Sprintf("目标端多出索引 %s (%s)", self.Name, self.Target)
+	default:
+		return fmt.Sprintf("%s不一致 %s: %s -> %s", self.Kind, self.Name, self.Source, self.Target)
+	}
+}
+
+func (self *ColumnDef) String() string {
+	text := self.Type
+	if !self.Nullable {
+		text += " not null"
+	}
+	if self.Default != nil {
+		text += " default " + *self.Default
+	}
+	return text
+}
+
+func (self *IndexDef) key() string {
+	//按列和唯一性匹配索引，两端的索引名经常不同
+	return fmt.Sprintf("%s|%t", strings.Join(self.Columns, ","), self.Unique)
+}
+
+func (self *TableDef) primary() *IndexDef {
+	for i := range self.Indexes {
+		if self.Indexes[i].Primary {
+			return &self.Indexes[i]
+		}
+	}
+	return nil
+}
+
+func CompareTableDef(source, target *TableDef, columnMap map[string]string) []SchemaDiff {
+	//对比两端的表结构，源端的列名按columnMap转换成目标端的列名；不比较列的顺序，类型不区分大小写
+	var diffs []SchemaDiff
+
+	targetColumns := make(map[string]*ColumnDef, len(target.Columns))
+	for i := range target.Columns {
+		targetColumns[target.Columns[i].Name] = &target.Columns[i]
+	}
+	sourceNames := make(map[string]bool, len(source.Columns))
+	for _, c := range source.Columns {
+		c.Name = MapNames([]string{c.Name}, columnMap)[0]
+		sourceNames[c.Name] = true
+		col := c
+		t, ok := targetColumns[c.Name]
+		if !ok {
+			diffs = append(diffs, SchemaDiff{Kind: "column_missing", Name: c.Name, Source: c.String(), Column: &col})
+			continue
+		}
+		if !strings.EqualFold(c.Type, t.Type) {
+			diffs = append(diffs, SchemaDiff{Kind: "column_type", Name: c.Name, Source: c.Type, Target: t.Type, Column: &col})
+		}
+		if c.Nullable != t.Nullable {
+			diffs = append(diffs, SchemaDiff{Kind: "column_nullable", Name: c.Name, Source: fmt.Sprint(c.Nullable), Target: fmt.Sprint(t.Nullable), Column: &col})
+		}
+		if defaultText(c.Default) != defaultText(t.Default) {
+			diffs = append(diffs, SchemaDiff{Kind: "column_default", Name: c.Name, Source: defaultText(c.Default), Target: defaultText(t.Default), Column: &col})
+		}
+	}
+	for i := range target.Columns {
+		if c := &target.Columns[i]; !sourceNames[c.Name] {
+			diffs = append(diffs, SchemaDiff{Kind: "column_extra", Name: c.Name, Target: c.String(), Column: c})
+		}
+	}
+
+	//主键
+	var sourcePrimary *IndexDef
+	if p := source.primary(); p != nil {
+		sourcePrimary = &IndexDef{Name: p.Name, Columns: MapNames(p.Columns, columnMap), Unique: true, Primary: true}
+	}
+	targetPrimary := target.primary()
+	var sourceKeys, targetKeys, targetName string
+	if sourcePrimary != nil {
+		sourceKeys = strings.Join(sourcePrimary.Columns, ",")
+	}
+	if targetPrimary != nil {
+		targetKeys, targetName = strings.Join(targetPrimary.Columns, ","), targetPrimary.Name
+	}
+	if sourceKeys != targetKeys {
+		diffs = append(diffs, SchemaDiff{Kind: "primary_key", Name: targetName, Source: sourceKeys, Target: targetKeys, Index: sourcePrimary})
+	}
+
+	//其他索引
+	targetIndexes := make(map[string]*IndexDef)
+	for i := range target.Indexes {
+		if idx := &target.Indexes[i]; !idx.Primary {
+			targetIndexes[idx.key()] = idx
+		}
+	}
+	for _, idx := range source.Indexes {
+		if idx.Primary {
+			continue
+		}
+		idx.Columns = MapNames(idx.Columns, columnMap)
+		if _, ok := targetIndexes[idx.key()]; ok {
+			delete(targetIndexes, idx.key())
+			continue
+		}
+		index := idx
+		diffs = append(diffs, SchemaDiff{Kind: "index_missing", Name: idx.Name, Source: strings.Join(idx.Columns, ","), Index: &index})
+	}
+	for i := range target.Indexes {
+		if idx := &target.Indexes[i]; targetIndexes[idx.key()] == idx {
+			diffs = append(diffs, SchemaDiff{Kind: "index_extra", Name: idx.Name, Target: strings.Join(idx.Columns, ","), Index: idx})
+		}
+	}
+
+	//其他属性
+	var names []string
+	for k := range source.Options {
+		names = append(names, k)
+	}
+	for k := range target.Options {
+		if _, ok := source.Options[k]; !ok {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		if source.Options[k] != target.Options[k] {
+			diffs = append(diffs, SchemaDiff{Kind: "option", Name: k, Source: source.Options[k], Target: target.Options[k]})
+		}
+	}
+	return diffs
+}
+
+func defaultText(v *string) string {
+	if v == nil {
+		return "<none>"
+	}
+	return *v
+}
+
+func BuildIndexes(rows [][]string) []IndexDef {
+	//按 索引名,是否唯一,是否主键,列名 的行组装索引，同一个索引的行按列的顺序排列
+	var indexes []IndexDef
+	for _, row := range rows {
+		n := len(indexes)
+		if n == 0 || indexes[n-1].Name != row[0] {
+			indexes = append(indexes, IndexDef{Name: row[0], Unique: isTrue(row[1]), Primary: isTrue(row[2])})
+			n++
+		}
+		indexes[n-1].Columns = append(indexes[n-1].Columns, row[3])
+	}
+	return indexes
+}
+
+func isTrue(v string) bool {
+	switch strings.ToLower(v) {
+	case "1", "t", "true", "yes", "y":
+		return true
+	}
+	return false
+}
+
+func BuildColumns(rows [][]any) []ColumnDef {
+	//按 列名,类型,是否可空,默认值 的行组装列定义，默认值为NULL表示没有默认值
+	columns := make([]ColumnDef, 0, len(rows))
+	for _, row := range rows {
+		c := ColumnDef{Name: fmt.Sprint(row[0]), Type: fmt.Sprint(row[1]), Nullable: isTrue(fmt.Sprint(row[2]))}
+		if row[3] != nil {
+			v := fmt.Sprint(row[3])
+			c.Default = &v
+		}
+		columns = append(columns, c)
+	}
+	return columns
+}
+
+func PickUniqueKey(rows [][]string) (string, []string) {
+	//按 索引名,列名,是否可空 的行选择列最少且所有列非空的唯一索引，作为没有主键的表的键，没有时返回空
+	var name string
+	var keys []string
+	for i := 0; i < len(rows); {
+		j, nullable := i, false
+		var columns []string
+		for ; j < len(rows) && rows[j][0] == rows[i][0]; j++ {
+			columns = append(columns, rows[j][1])
+			nullable = nullable || isTrue(rows[j][2])
+		}
+		if !nullable && (keys == nil || len(columns) < len(keys)) {
+			name, keys = rows[i][0], columns
+		}
+		i = j
+	}
+	return name, keys
+}
@@ -0,0 +1,86 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCompareTableDef(t *testing.T) {
+	zero := "0"
+	source := &TableDef{
+		Columns: []ColumnDef{
+			{Name: "id", Type: "int", Nullable: false},
+			{Name: "name", Type: "varchar(20)", Nullable: true},
+			{Name: "amount", Type: "decimal(10,2)", Nullable: false, Default: &zero},
+			{Name: "memo", Type: "text", Nullable: true},
+		},
+		Indexes: []IndexDef{
+			{Name: "PRIMARY", Columns: []string{"id"}, Unique: true, Primary: true},
+			{Name: "idx_name", Columns: []string{"name"}},
+			{Name: "uk_amount", Columns: []string{"amount", "id"}, Unique: true},
+		},
+	}
+	target := &TableDef{
+		Columns: []ColumnDef{
+			{Name: "id", Type: "INT", Nullable: false},
+			{Name: "user_name", Type: "varchar(30)", Nullable: false},
+			{Name: "amount", Type: "decimal(10,2)", Nullable: false},
+			{Name: "etl_time", Type: "datetime", Nullable: true},
+		},
+		Indexes: []IndexDef{
+			{Name: "PRIMARY", Columns: []string{"id"}, Unique: true, Primary: true},
+			{Name: "ix_user_name", Columns: []string{"user_name"}},
+			{Name: "ix_etl_time", Columns: []string{"etl_time"}},
+		},
+	}
+
+	var kinds []string
+	for _, d := range CompareTableDef(source, target, map[string]string{"name": "user_name"}) {
+		kinds = append(kinds, d.Kind+":"+d.Name)
+	}
+	expected := []string{
+		"column_type:user_name", "column_nullable:user_name", "column_default:amount", "column_missing:memo", "column_extra:etl_time",
+		"index_missing:uk_amount", "index_extra:ix_etl_time",
+	}
+	if !reflect.DeepEqual(kinds, expected) {
+		t.Fatalf("CompareTableDef = %v, expected %v", kinds, expected)
+	}
+
+	//主键不同，目标端没有主键
+	target.Indexes = target.Indexes[1:]
+	diffs := CompareTableDef(source, target, map[string]string{"name": "user_name"})
+	found := false
+	for _, d := range diffs {
+		if d.Kind == "primary_key" {
+			found = d.Name == "" && d.Source == "id" && d.Index != nil && d.Index.Primary
+		}
+	}
+	if !found {
+		t.Fatalf("expected a primary_key diff, got %v", diffs)
+	}
+}
+
+func TestBuildIndexes(t *testing.T) {
+	rows := [][]string{{"PRIMARY", "1", "1", "id"}, {"idx_a_b", "0", "0", "a"}, {"idx_a_b", "0", "0", "b"}}
+	expected := []IndexDef{
+		{Name: "PRIMARY", Columns: []string{"id"}, Unique: true, Primary: true},
+		{Name: "idx_a_b", Columns: []string{"a", "b"}},
+	}
+	if indexes := BuildIndexes(rows); !reflect.DeepEqual(indexes, expected) {
+		t.Fatalf("BuildIndexes = %+v", indexes)
+	}
+}
+
+func TestPickUniqueKey(t *testing.T) {
+	rows := [][]string{
+		{"uk_a_b", "a", "NO"}, {"uk_a_b", "b", "NO"},
+		{"uk_c", "c", "YES"},
+		{"uk_d", "d", "NO"},
+	}
+	if name, keys := PickUniqueKey(rows); name != "uk_d" || !reflect.DeepEqual(keys, []string{"d"}) {
+		t.Fatalf("PickUniqueKey = %s %v", name, keys)
+	}
+	if name, keys := PickUniqueKey(rows[2:3]); name != "" || keys != nil {
+		t.Fatalf("PickUniqueKey = %s %v, expected none", name, keys)
+	}
+}
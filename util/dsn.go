@@ -0,0 +1,174 @@
+package util
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql"
+	"fmt"
+	"github.com/go-sql-driver/mysql"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+var tlsConfigSeq atomic.Int32
+
+func OpenDsn(dbType, dsn, database string) (db *sql.DB, err error) {
+	//使用用户指定的连接串连接数据库，连接串中的库名会被替换成db参数中的库名
+	driver, dsn, err := WithDatabase(dbType, dsn, database)
+	if err != nil {
+		return nil, fmt.Errorf("OpenDsn -> %w", err)
+	}
+	db, err = sql.Open(driver, dsn)
+	if err != nil {
+		return
+	}
+	db.SetMaxOpenConns(64)                    //最大连接数
+	db.SetMaxIdleConns(32)                    //连接池里最大空闲连接数。必须要比maxOpenConns小
+	db.SetConnMaxLifetime(time.Second * 3600) //最大存活保持时间
+	db.SetConnMaxIdleTime(time.Second * 3600) //最大空闲保持时间
+	return
+}
+
+func WithDatabase(dbType, dsn, database string) (driver string, text string, err error) {
+	//返回驱动名和指定了库名的连接串
+	switch dbType {
+	case "mysql", "doris", "tidb", "oceanbase":
+		text, err = mysqlWithDatabase(dsn, database)
+		return "mysql", text, err
+	case "pgsql":
+		if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+			u, err := url.Parse(dsn)
+			if err != nil {
+				return "", "", fmt.Errorf("WithDatabase -> %w", err)
+			}
+			u.Path = "/" + database
+			return "postgres", u.String(), nil
+		}
+		//key=value格式，后面的参数覆盖前面的参数
+		return "postgres", fmt.Sprintf("%s dbname='%s'", dsn, strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(database)), nil
+	case "mssql":
+		if strings.HasPrefix(dsn, "sqlserver://") {
+			u, err := url.Parse(dsn)
+			if err != nil {
+				return "", "", fmt.Errorf("WithDatabase -> %w", err)
+			}
+			q := u.Query()
+			q.Set("database", database)
+			u.RawQuery = q.Encode()
+			return "sqlserver", u.String(), nil
+		}
+		return "sqlserver", fmt.Sprintf("%s;database=%s", strings.TrimSuffix(dsn, ";"), database), nil
+	default:
+		return "", "", fmt.Errorf("WithDatabase:Unsupported database type %s", dbType)
+	}
+}
+
+func mysqlWithDatabase(dsn, database string) (string, error) {
+	//go-sql-driver不支持证书文件参数，ssl-ca/ssl-cert/ssl-key从连接串中取出后注册成tls配置
+	var tlsConfig *tls.Config
+	if i := strings.IndexByte(dsn, '?'); i >= 0 {
+		query, err := url.ParseQuery(dsn[i+1:])
+		if err != nil {
+			return "", fmt.Errorf("mysqlWithDatabase -> %w", err)
+		}
+		if ca, cert, key := query.Get("ssl-ca"), query.Get("ssl-cert"), query.Get("ssl-key"); ca != "" || cert != "" {
+			tlsConfig, err = newTLSConfig(ca, cert, key)
+			if err != nil {
+				return "", fmt.Errorf("mysqlWithDatabase -> %w", err)
+			}
+			query.Del("ssl-ca")
+			query.Del("ssl-cert")
+			query.Del("ssl-key")
+			dsn = dsn[:i+1] + query.Encode()
+		}
+	}
+
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return "", fmt.Errorf("mysqlWithDatabase -> %w", err)
+	}
+	cfg.DBName = database
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	if tlsConfig != nil {
+		tlsConfig.ServerName, _, _ = strings.Cut(cfg.Addr, ":")
+		name := fmt.Sprintf("checkdata%d", tlsConfigSeq.Add(1))
+		if err = mysql.RegisterTLSConfig(name, tlsConfig); err != nil {
+			return "", fmt.Errorf("mysqlWithDatabase -> %w", err)
+		}
+		cfg.TLSConfig = name
+	}
+	return cfg.FormatDSN(), nil
+}
+
+func newTLSConfig(ca, cert, key string) (*tls.Config, error) {
+	cfg := &tls.Config{}
+	if ca != "" {
+		pem, err := os.ReadFile(ca)
+		if err != nil {
+			return nil, fmt.Errorf("newTLSConfig -> %w", err)
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("newTLSConfig:无效的CA证书 %s", ca)
+		}
+	}
+	if cert != "" {
+		pair, err := tls.LoadX509KeyPair(cert, key)
+		if err != nil {
+			return nil, fmt.Errorf("newTLSConfig -> %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{pair}
+	}
+	return cfg, nil
+}
+
+func DsnAddr(dsn string) (host string, port string) {
+	//从连接串中取出第一个地址，用于命名结果目录，取不到时返回空字符串
+	if u, err := url.Parse(dsn); err == nil && u.Scheme != "" && u.Host != "" {
+		//mongo的多个地址用逗号分隔
+		addr, _, _ := strings.Cut(u.Host, ",")
+		host, port, _ = strings.Cut(addr, ":")
+		return host, port
+	}
+	if strings.Contains(dsn, "@tcp(") {
+		// mysql: user:pwd@tcp(host:port)/db
+		if cfg, err := mysql.ParseDSN(dsn); err == nil {
+			host, port, _ = strings.Cut(cfg.Addr, ":")
+		}
+		return host, port
+	}
+	//key=value格式: host=x port=y 或 server=x,y;
+	for _, field := range strings.FieldsFunc(dsn, func(r rune) bool { return r == ' ' || r == ';' }) {
+		k, v, _ := strings.Cut(field, "=")
+		switch strings.ToLower(k) {
+		case "host", "server", "address":
+			host, port, _ = strings.Cut(v, ",")
+		case "port":
+			port = v
+		}
+	}
+	return host, port
+}
+
+var dsnPassword = regexp.MustCompile(`(?i)\b(password|pwd)\s*=\s*('[^']*'|[^ ;]*)`)
+
+func MaskDsn(dsn string) string {
+	//隐藏连接串中的密码，用于写入报告和日志
+	if u, err := url.Parse(dsn); err == nil && u.Scheme != "" && u.User != nil {
+		return u.Redacted()
+	}
+	if i := strings.LastIndex(dsn, "@tcp("); i > 0 {
+		// mysql: user:pwd@tcp(host:port)/db
+		if j := strings.Index(dsn[:i], ":"); j >= 0 {
+			return dsn[:j+1] + "******" + dsn[i:]
+		}
+		return dsn
+	}
+	return dsnPassword.ReplaceAllString(dsn, "$1=******")
+}
@@ -0,0 +1,25 @@
+package util
+
+import (
+	"strconv"
+)
+
+/*
+行数据计算摘要前的编码规则，保证不同的行不会得到相同的编码:
+NULL   : N
+非NULL : 长度:值，如 'abc' -> 3:abc，'' -> 0:
+按列的顺序直接拼接，如 (NULL,'a') -> N1:a，('a',NULL) -> 1:aN，('a|b','') -> 3:a|b0:
+由于每个值都带有长度前缀，值中包含任何字符都不会影响其他列
+fast模式下各数据库按同样的规则拼接（长度的计算方式由数据库决定，两端一致即可）
+*/
+
+const EncodedNull = 'N'
+
+func AppendEncodedValue(buf []byte, value []byte, isNull bool) []byte {
+	if isNull {
+		return append(buf, EncodedNull)
+	}
+	buf = strconv.AppendInt(buf, int64(len(value)), 10)
+	buf = append(buf, ':')
+	return append(buf, value...)
+}
@@ -0,0 +1,45 @@
+package util
+
+import (
+	"testing"
+)
+
+func encodeRow(values []*string) string {
+	var buf []byte
+	for _, v := range values {
+		if v == nil {
+			buf = AppendEncodedValue(buf, nil, true)
+		} else {
+			buf = AppendEncodedValue(buf, []byte(*v), false)
+		}
+	}
+	return string(buf)
+}
+
+func TestAppendEncodedValue(t *testing.T) {
+	a, b, empty, null, sep := "a", "b", "", "NULL", "a|b"
+	rows := [][]*string{
+		{nil, &a},
+		{&a, nil},
+		{&empty, &a},
+		{&a, &empty},
+		{&null, &a},
+		{&sep, &empty},
+		{&a, &b},
+		{&empty, &empty},
+		{nil, nil},
+	}
+
+	seen := make(map[string]int)
+	for i, row := range rows {
+		text := encodeRow(row)
+		if j, ok := seen[text]; ok {
+			t.Fatalf("row %d and row %d have the same encoding: %s", j, i, text)
+		}
+		seen[text] = i
+	}
+
+	if text := encodeRow([]*string{&sep, nil, &empty}); text != "3:a|bN0:" {
+		t.Fatalf("unexpected encoding: %s", text)
+	}
+}
@@ -0,0 +1,46 @@
+package util
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/cespare/xxhash/v2"
+	"hash/crc32"
+	"strconv"
+)
+
+/*
+行数据的摘要算法，两端必须使用同一种算法
+crc32   : 32位，速度最快，数据量大时容易碰撞，结果为10进制数字（和数据库的crc32函数一致）
+xxhash64: 64位，速度快，结果为16进制
+md5     : 128位，结果为16进制
+sha256  : 截取前128位，结果为16进制
+*/
+
+var HashNames = []string{"crc32", "xxhash64", "md5", "sha256"}
+
+func NewHashFunc(name string) (func([]byte) string, error) {
+	switch name {
+	case "", "crc32":
+		return func(buf []byte) string {
+			return strconv.FormatUint(uint64(crc32.ChecksumIEEE(buf)), 10)
+		}, nil
+	case "xxhash64":
+		return func(buf []byte) string {
+			return strconv.FormatUint(xxhash.Sum64(buf), 16)
+		}, nil
+	case "md5":
+		return func(buf []byte) string {
+			sum := md5.Sum(buf)
+			return hex.EncodeToString(sum[:])
+		}, nil
+	case "sha256":
+		return func(buf []byte) string {
+			sum := sha256.Sum256(buf)
+			return hex.EncodeToString(sum[:16])
+		}, nil
+	default:
+		return nil, fmt.Errorf("NewHashFunc:Unsupported hash %s, must be one of %v", name, HashNames)
+	}
+}
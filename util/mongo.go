@@ -16,11 +16,15 @@ type MongoDB struct {
 	User     string
 	Password string
 	Database string
+	Uri      string //用户指定的连接串，如副本集、SRV、TLS等，为空时使用Host/Port/User/Password
 	Client   *mongo.Client
 }
 
 func (self *MongoDB) Init() (err error) {
-	dsn := fmt.Sprintf("mongodb://%s:%s@%s:%d/?connect=direct;authSource=admin", self.User, self.Password, self.Host, self.Port)
+	dsn := self.Uri
+	if dsn == "" {
+		dsn = fmt.Sprintf("mongodb://%s:%s@%s:%d/?connect=direct;authSource=admin", self.User, self.Password, self.Host, self.Port)
+	}
 	clientOptions := options.Client().ApplyURI(dsn)
 	client, err := mongo.Connect(context.TODO(), clientOptions)
 	if err != nil {
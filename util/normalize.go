@@ -0,0 +1,307 @@
+package util
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"golang.org/x/text/unicode/norm"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+/*
+跨数据库核对时，不同数据库(驱动)返回的同一个值格式不一样，比如:
+mysql的tinyint(1)返回1/0，pgsql的bool返回true/false
+mysql的datetime返回"2006-01-02 15:04:05"，pgsql/mssql的驱动返回"2006-01-02T15:04:05Z"
+decimal(10,2)和numeric(12,4)返回的小数位数不一样
+这里根据列的类型把值转换成统一的格式，再计算校验和
+*/
+
+type ValueKind int
+
+const (
+	KindString ValueKind = iota
+	KindChar
+	KindNumber
+	KindFloat
+	KindBool
+	KindDate
+	KindDatetime
+	KindTime
+	KindBinary
+	KindJson
+	KindUuid
+)
+
+const normalizeTimeLayout = "2006-01-02 15:04:05.999999"
+
+var timeLayouts = []string{
+	"2006-01-02 15:04:05.999999999",
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999 -0700 MST",
+	"2006-01-02",
+}
+
+func GetValueKind(typeName string) ValueKind {
+	//根据驱动返回的类型名(ColumnType.DatabaseTypeName)判断值的类型
+	typeName = strings.TrimPrefix(strings.ToUpper(typeName), "UNSIGNED ")
+	switch typeName {
+	case "DECIMAL", "NUMERIC", "INT", "INTEGER", "INT2", "INT4", "INT8", "BIGINT", "SMALLINT", "TINYINT", "MEDIUMINT", "YEAR", "MONEY", "SMALLMONEY":
+		return KindNumber
+	case "FLOAT", "DOUBLE", "REAL", "FLOAT4", "FLOAT8":
+		return KindFloat
+	case "BOOL", "BOOLEAN", "BIT":
+		return KindBool
+	case "DATE":
+		return KindDate
+	case "DATETIME", "DATETIME2", "SMALLDATETIME", "TIMESTAMP", "TIMESTAMPTZ", "DATETIMEOFFSET":
+		return KindDatetime
+	case "TIME", "TIMETZ":
+		return KindTime
+	case "BLOB", "TINYBLOB", "MEDIUMBLOB", "LONGBLOB", "BINARY", "VARBINARY", "BYTEA", "IMAGE":
+		return KindBinary
+	case "CHAR", "BPCHAR", "NCHAR":
+		return KindChar
+	case "JSON", "JSONB":
+		return KindJson
+	case "UUID", "UNIQUEIDENTIFIER":
+		return KindUuid
+	default:
+		return KindString
+	}
+}
+
+func GetValueKinds(cur *sql.Rows) ([]ValueKind, error) {
+	//获取结果集每一列的值类型
+	types, err := cur.ColumnTypes()
+	if err != nil {
+		return nil, fmt.Errorf("GetValueKinds -> %w", err)
+	}
+	kinds := make([]ValueKind, len(types))
+	for i, t := range types {
+		kinds[i] = GetValueKind(t.DatabaseTypeName())
+	}
+	return kinds, nil
+}
+
+func NormalizeValue(val []byte, kind ValueKind) string {
+	//把值转换成统一的格式，NULL需要调用方自行处理
+	switch kind {
+	case KindNumber:
+		return normalizeNumber(string(val))
+	case KindFloat:
+		f, err := strconv.ParseFloat(string(val), 64)
+		if err != nil {
+			return string(val)
+		}
+		return strconv.FormatFloat(f, 'g', 15, 64)
+	case KindBool:
+		return normalizeBool(val)
+	case KindDate:
+		if t, ok := parseTime(string(val)); ok {
+			return t.Format("2006-01-02")
+		}
+		return string(val)
+	case KindDatetime:
+		if t, ok := parseTime(string(val)); ok {
+			return t.Format(normalizeTimeLayout)
+		}
+		return string(val)
+	case KindTime:
+		return normalizeClock(string(val))
+	case KindBinary:
+		return hex.EncodeToString(val)
+	case KindChar:
+		return normalizeString(bytes.TrimRight(val, " "))
+	case KindJson:
+		return normalizeJson(val)
+	case KindUuid:
+		return normalizeUuid(val)
+	default:
+		return normalizeString(val)
+	}
+}
+
+func normalizeNumber(s string) string {
+	//去掉正号、前导0和小数末尾的0: +001.500 -> 1.5, -0.00 -> 0
+	s = strings.TrimSpace(s)
+	neg := false
+	if strings.HasPrefix(s, "-") {
+		neg = true
+		s = s[1:]
+	} else {
+		s = strings.TrimPrefix(s, "+")
+	}
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(s, "0")
+		s = strings.TrimSuffix(s, ".")
+	}
+	s = strings.TrimLeft(s, "0")
+	if s == "" || strings.HasPrefix(s, ".") {
+		s = "0" + s
+	}
+	if neg && s != "0" {
+		s = "-" + s
+	}
+	return s
+}
+
+func normalizeBool(val []byte) string {
+	//mysql的bit(1)返回\x01，mssql的bit和pgsql的bool返回true/false
+	switch strings.ToLower(string(val)) {
+	case "1", "t", "true", "y", "yes", "\x01":
+		return "1"
+	case "0", "f", "false", "n", "no", "\x00":
+		return "0"
+	}
+	//mysql的bit(n)按大端整数处理
+	var n uint64
+	for _, b := range val {
+		n = n<<8 | uint64(b)
+	}
+	return strconv.FormatUint(n, 10)
+}
+
+func parseTime(s string) (time.Time, bool) {
+	//时区信息直接丢弃，只保留驱动返回的本地时间
+	for _, layout := range timeLayouts {
+		t, err := time.Parse(layout, s)
+		if err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func normalizeClock(s string) string {
+	//time类型: 去掉小数秒末尾的0和时区
+	if i := strings.IndexAny(s, "+Z"); i > 0 {
+		s = s[:i]
+	}
+	if t, ok := parseTime(s); ok {
+		return t.Format("15:04:05.999999")
+	}
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(s, "0")
+		s = strings.TrimSuffix(s, ".")
+	}
+	return s
+}
+
+func normalizeString(val []byte) string {
+	//合法的utf8字符串统一成NFC格式，非法的按二进制处理
+	if !utf8.Valid(val) {
+		return hex.EncodeToString(val)
+	}
+	return norm.NFC.String(string(val))
+}
+
+func normalizeJson(val []byte) string {
+	//按key排序、去掉空白
+	var v any
+	if err := json.Unmarshal(val, &v); err != nil {
+		return normalizeString(val)
+	}
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return normalizeString(val)
+	}
+	return string(buf)
+}
+
+func normalizeUuid(val []byte) string {
+	//mssql的uniqueidentifier返回16字节，前3段是小端序
+	if len(val) == 16 {
+		b := []byte{val[3], val[2], val[1], val[0], val[5], val[4], val[7], val[6]}
+		b = append(b, val[8:]...)
+		h := hex.EncodeToString(b)
+		return fmt.Sprintf("%s-%s-%s-%s-%s", h[0:8], h[8:12], h[12:16], h[16:20], h[20:])
+	}
+	return strings.ToLower(string(val))
+}
+
+func QueryReturnNormalizedDict(db *sql.DB, sqlText string) ([]map[string]string, error) {
+	//执行sql，返回统一格式后的二维map
+	cur, err := db.Query(sqlText)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close()
+
+	cols, err := cur.Columns()
+	if err != nil {
+		return nil, err
+	}
+	kinds, err := GetValueKinds(cur)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]sql.RawBytes, len(cols))
+	valuesP := make([]interface{}, len(cols))
+	for i := range values {
+		valuesP[i] = &values[i]
+	}
+
+	data := []map[string]string{}
+	for cur.Next() {
+		err := cur.Scan(valuesP...)
+		if err != nil {
+			return nil, err
+		}
+		row := make(map[string]string, len(cols))
+		for i, v := range values {
+			if v == nil {
+				row[cols[i]] = "NULL"
+			} else {
+				row[cols[i]] = NormalizeValue(v, kinds[i])
+			}
+		}
+		data = append(data, row)
+	}
+	return data, cur.Err()
+}
+
+func QueryReturnNormalizedList(db *sql.DB, sqlText string) (rows [][]any, kinds []ValueKind, err error) {
+	//执行sql，返回统一格式后的二维数组，NULL值为nil
+	cur, err := db.Query(sqlText)
+	if err != nil {
+		return
+	}
+	defer cur.Close()
+
+	kinds, err = GetValueKinds(cur)
+	if err != nil {
+		return
+	}
+
+	values := make([]sql.RawBytes, len(kinds))
+	valuesP := make([]interface{}, len(kinds))
+	for i := range values {
+		valuesP[i] = &values[i]
+	}
+
+	for cur.Next() {
+		err = cur.Scan(valuesP...)
+		if err != nil {
+			return
+		}
+		row := make([]any, len(kinds))
+		for i, v := range values {
+			if v == nil {
+				row[i] = nil
+			} else {
+				row[i] = NormalizeValue(v, kinds[i])
+			}
+		}
+		rows = append(rows, row)
+	}
+	err = cur.Err()
+	return
+}
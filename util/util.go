@@ -36,6 +36,11 @@ func Mkdir(dirName string) error {
 	return nil
 }
 
+func FileExists(fileName string) bool {
+	_, err := os.Stat(fileName)
+	return err == nil
+}
+
 func RemoveSliceElement[T comparable](list *[]T, index int) {
 	//删除slice某个元素
 	*list = append((*list)[:index], (*list)[index+1:]...)
@@ -58,3 +58,25 @@ func estimateTotalLength(fields []string, values []string, sepLen int) int {
 	}
 	return total
 }
+
+func GenerateWhereClause(fields []string, values []any, quote string, escapeFunc func(string) string) (string, error) {
+	//生成主键的where条件，NULL值使用is null
+	if len(fields) != len(values) {
+		return "", fmt.Errorf("fields and values length not equal")
+	}
+
+	var buf strings.Builder
+	for i, f := range fields {
+		if i > 0 {
+			buf.WriteString(" AND ")
+		}
+		buf.WriteString(EncloseStr(f, quote))
+		if values[i] == nil {
+			buf.WriteString(" IS NULL")
+		} else {
+			buf.WriteString("=")
+			buf.WriteString(EncloseValue(values[i], escapeFunc))
+		}
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,70 @@
+package watch
+
+import (
+	"checkData/db/mongo"
+	"checkData/db/mysql"
+	"checkData/db/pgsql"
+	"checkData/model"
+	"checkData/util"
+	"fmt"
+	"github.com/gookit/slog"
+	"os"
+)
+
+func Start(opt *model.Options) {
+	util.EnterWorkDir()
+
+	if len(opt.DbGroupList) != 1 {
+		slog.Error("持续核对只支持一个数据库")
+		os.Exit(1)
+	}
+	dbg := opt.DbGroupList[0]
+
+	err := util.Mkdir(fmt.Sprintf("%s/%s", opt.BaseDir, dbg[1]))
+	if err != nil {
+		slog.Error(err)
+		os.Exit(1)
+	}
+
+	//创建数据库对象和变更源
+	var db model.Database
+	var src model.ChangeSource
+	switch opt.DbType {
+	case "mysql":
+		db, err = mysql.NewDatabase(opt, dbg)
+		if err == nil {
+			err = db.PreCheck()
+		}
+		if err == nil {
+			src, err = mysql.NewBinlogSource(db, opt)
+		}
+	case "pgsql":
+		db, err = pgsql.NewDatabase(opt, dbg)
+		if err == nil {
+			err = db.PreCheck()
+		}
+		if err == nil {
+			src, err = pgsql.NewLogicalSource(db, opt)
+		}
+	case "mongo":
+		db, err = mongo.NewDatabase(opt, dbg)
+		if err == nil {
+			err = db.PreCheck()
+		}
+		if err == nil {
+			src, err = mongo.NewChangeStreamSource(db, opt)
+		}
+	default:
+		slog.Errorf("不支持持续核对的数据库类型:%s", opt.DbType)
+		os.Exit(1)
+	}
+
+	if err != nil {
+		slog.Errorf("[%s:%s] 开始持续核对报错：%s", dbg[0], dbg[1], err)
+		os.Exit(1)
+	}
+	defer db.Close()
+	defer src.Close()
+
+	NewWatcher(db, src, opt, dbg[1]).Run()
+}
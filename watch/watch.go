@@ -0,0 +1,237 @@
+package watch
+
+import (
+	"checkData/model"
+	"checkData/threading"
+	"checkData/util"
+	"errors"
+	"fmt"
+	"github.com/gookit/slog"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+/*
+Watcher 持续核对源端有变更的数据
+从ChangeSource读取变更的主键，按表缓存，超过延迟窗口(--lag)后使用Table.Recheck复核，
+复核不通过的主键在下一个延迟窗口后再次复核，连续max-recheck-times次不通过才认为数据不一致
+变更流结束或读取报错时，不等待延迟窗口复核剩余的主键，不通过的认为数据不一致
+需要确认消费的变更流(CommitChangeSource)，一批变更都复核完成后才调用Commit
+*/
+type Watcher struct {
+	Db      model.Database
+	DbName  string //目标端的库名，结果文件使用
+	Source  model.ChangeSource
+	Options *model.Options
+	tables  map[string]model.Table
+	pending map[string]map[model.Key]*pendingKey
+}
+
+type pendingKey struct {
+	since time.Time //最近一次变更或复核的时间
+	times int       //已复核的次数
+}
+
+func NewWatcher(db model.Database, src model.ChangeSource, opt *model.Options, dbName string) *Watcher {
+	return &Watcher{
+		Db:      db,
+		DbName:  dbName,
+		Source:  src,
+		Options: opt,
+		tables:  make(map[string]model.Table),
+		pending: make(map[string]map[model.Key]*pendingKey),
+	}
+}
+
+func (self *Watcher) Run() {
+	slog.Infof("[%s] 开始持续核对 [lag:%ds interval:%ds]", self.DbName, self.Options.WatchLag, self.Options.WatchInterval)
+
+	csvFile := fmt.Sprintf("%s/%s.watch.csv", self.Options.BaseDir, self.DbName)
+	if !util.FileExists(csvFile) {
+		util.WriteFile(csvFile, "Time,DbName,TableName,RecheckRows,PassRows,DriftRows\n")
+	}
+
+	changes := make(chan *model.Change, 10000)
+	committed := make(chan struct{}, 1)
+	go func() {
+		defer close(changes)
+		for {
+			change, err := self.Source.Next()
+			if errors.Is(err, model.ErrCommitRequired) {
+				//一批变更读取完，发送nil通知复核，确认消费后再继续读取
+				changes <- nil
+				<-committed
+				continue
+			} else if errors.Is(err, io.EOF) {
+				slog.Infof("[%s] 变更流已结束", self.DbName)
+				return
+			} else if err != nil {
+				slog.Errorf("[%s] 读取变更报错：%s", self.DbName, err)
+				return
+			}
+			changes <- change
+		}
+	}()
+
+	//这一批变更都复核完成(没有待复核的主键)后确认消费，失败时下一次复核后重试
+	committer, _ := self.Source.(model.CommitChangeSource)
+	batchEnd := false
+	commit := func() {
+		if !batchEnd || len(self.pending) > 0 {
+			return
+		}
+		if err := committer.Commit(); err != nil {
+			slog.Errorf("[%s] 确认消费变更报错：%s", self.DbName, err)
+			return
+		}
+		batchEnd = false
+		committed <- struct{}{}
+	}
+
+	ticker := time.NewTicker(time.Duration(self.Options.WatchInterval) * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case change, ok := <-changes:
+			if !ok {
+				self.Flush(time.Now())
+				if committer != nil {
+					if err := committer.Commit(); err != nil {
+						slog.Errorf("[%s] 确认消费变更报错：%s", self.DbName, err)
+					}
+				}
+				return
+			}
+			if change == nil {
+				batchEnd = true
+				commit()
+				continue
+			}
+			self.Add(change)
+		case <-ticker.C:
+			self.RecheckDue(time.Now())
+			if committer != nil {
+				commit()
+			}
+		case <-threading.SignalChan:
+			slog.Infof("收到kill信号，准备退出程序")
+			return
+		}
+	}
+}
+
+func (self *Watcher) Add(change *model.Change) {
+	keys, ok := self.pending[change.Table]
+	if !ok {
+		keys = make(map[model.Key]*pendingKey)
+		self.pending[change.Table] = keys
+	}
+	//再次变更时重新计算延迟窗口
+	keys[change.Key] = &pendingKey{since: time.Now()}
+}
+
+func (self *Watcher) RecheckDue(now time.Time) {
+	//复核超过延迟窗口的主键
+	self.recheck(now, false)
+}
+
+func (self *Watcher) Flush(now time.Time) {
+	//变更流结束时复核所有剩余的主键，不通过的直接认为不一致
+	rows := 0
+	for _, keys := range self.pending {
+		rows += len(keys)
+	}
+	if rows > 0 {
+		slog.Infof("[%s] 复核剩余的 %d 行变更数据", self.DbName, rows)
+	}
+	self.recheck(now, true)
+}
+
+func (self *Watcher) recheck(now time.Time, final bool) {
+	deadline := now.Add(-time.Duration(self.Options.WatchLag) * time.Second)
+
+	tbNames := make([]string, 0, len(self.pending))
+	for tbName := range self.pending {
+		tbNames = append(tbNames, tbName)
+	}
+	sort.Strings(tbNames)
+
+	for _, tbName := range tbNames {
+		keys := self.pending[tbName]
+		var ids []model.Key
+		for id, p := range keys {
+			if final || p.since.Before(deadline) {
+				ids = append(ids, id)
+			}
+		}
+		if len(ids) == 0 {
+			continue
+		}
+
+		tb := self.getTable(tbName)
+		if tb == nil {
+			delete(self.pending, tbName)
+			continue
+		}
+
+		passList := tb.Recheck(ids)
+		for _, id := range passList {
+			delete(keys, id)
+		}
+
+		util.RemoveSliceMultiElement(&ids, &passList)
+		var drift []model.Key
+		for _, id := range ids {
+			p := keys[id]
+			p.times++
+			p.since = now
+			if final || p.times >= self.Options.MaxRecheckTimes {
+				drift = append(drift, id)
+				delete(keys, id)
+			}
+		}
+		if len(keys) == 0 {
+			delete(self.pending, tbName)
+		}
+
+		self.report(now, tbName, len(passList)+len(ids), len(passList), drift)
+	}
+}
+
+func (self *Watcher) getTable(tbName string) model.Table {
+	//预检查通过的表才能复核，预检查失败的表不再核对
+	if tb, ok := self.tables[tbName]; ok {
+		return tb
+	}
+
+	var tb model.Table
+	if util.InSlice(tbName, self.Db.GetTableInfo().ToCheck) {
+		tb = self.Db.NewTable(tbName)
+		if !tb.PreCheck() {
+			slog.Errorf("[%s.%s] 预检查不通过，忽略这个表的变更", self.DbName, tbName)
+			tb = nil
+		}
+	}
+	self.tables[tbName] = tb
+	return tb
+}
+
+func (self *Watcher) report(now time.Time, tbName string, rechecked int, passed int, drift []model.Key) {
+	slog.Infof("[%s.%s] 复核变更数据 [RecheckRows:%d PassRows:%d DriftRows:%d]", self.DbName, tbName, rechecked, passed, len(drift))
+
+	csvFile := fmt.Sprintf("%s/%s.watch.csv", self.Options.BaseDir, self.DbName)
+	util.WriteFileTail(csvFile, fmt.Sprintf("%s,%s,%s,%d,%d,%d\n", now.Format("2006-01-02 15:04:05"), self.DbName, tbName, rechecked, passed, len(drift)))
+
+	if len(drift) > 0 {
+		var buf strings.Builder
+		for _, id := range drift {
+			buf.WriteString(id.String())
+			buf.WriteString("\n")
+		}
+		driftFile := fmt.Sprintf("%s/%s/%s.drift", self.Options.BaseDir, self.DbName, tbName)
+		util.WriteFileTail(driftFile, buf.String())
+		slog.Warnf("[%s.%s] 发现 %d 行数据不一致，主键保存在: %s", self.DbName, tbName, len(drift), driftFile)
+	}
+}
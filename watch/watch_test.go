@@ -0,0 +1,170 @@
+package watch
+
+import (
+	"checkData/model"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fakeTable 复核时pass中的主键通过，记录每次复核的主键
+type fakeTable struct {
+	model.Table
+	pass    map[model.Key]bool
+	checked [][]model.Key
+	events  *[]string //不为nil时记录复核的主键
+}
+
+func (self *fakeTable) PreCheck() bool { return true }
+
+func (self *fakeTable) Recheck(ids []model.Key) (passList []model.Key) {
+	self.checked = append(self.checked, ids)
+	if self.events != nil {
+		*self.events = append(*self.events, fmt.Sprint(ids))
+	}
+	for _, id := range ids {
+		if self.pass[id] {
+			passList = append(passList, id)
+		}
+	}
+	return passList
+}
+
+type fakeDatabase struct {
+	model.Database
+	tables map[string]*fakeTable
+}
+
+func (self *fakeDatabase) GetTableInfo() *model.TableInfo {
+	return &model.TableInfo{ToCheck: []string{"t1"}}
+}
+
+func (self *fakeDatabase) NewTable(tb string) model.Table {
+	return self.tables[tb]
+}
+
+func TestRecheckDue(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, "db1"), 0775)
+	tb := &fakeTable{pass: map[model.Key]bool{"1": true}}
+	opt := &model.Options{BaseDir: dir, WatchLag: 60, MaxRecheckTimes: 2}
+	w := NewWatcher(&fakeDatabase{tables: map[string]*fakeTable{"t1": tb}}, nil, opt, "db1")
+
+	w.Add(&model.Change{Table: "t1", Key: "1"})
+	w.Add(&model.Change{Table: "t1", Key: "2"})
+	w.Add(&model.Change{Table: "t2", Key: "1"})
+	now := time.Now()
+
+	//没有超过延迟窗口时不复核
+	w.RecheckDue(now)
+	if len(tb.checked) != 0 {
+		t.Fatalf("rechecked before the lag: %v", tb.checked)
+	}
+
+	//1通过，2第一次不通过，不需要核对的表t2被忽略
+	w.RecheckDue(now.Add(61 * time.Second))
+	if len(tb.checked) != 1 || len(tb.checked[0]) != 2 {
+		t.Fatalf("checked = %v", tb.checked)
+	}
+	if _, ok := w.pending["t2"]; ok || len(w.pending["t1"]) != 1 || w.pending["t1"]["2"].times != 1 {
+		t.Fatalf("pending = %v", w.pending)
+	}
+
+	//不通过的主键在下一个延迟窗口后再次复核
+	w.RecheckDue(now.Add(62 * time.Second))
+	if len(tb.checked) != 1 {
+		t.Fatalf("rechecked within the lag: %v", tb.checked)
+	}
+	w.RecheckDue(now.Add(122 * time.Second))
+	if len(tb.checked) != 2 || len(w.pending) != 0 {
+		t.Fatalf("checked = %v, pending = %v", tb.checked, w.pending)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "db1", "t1.drift"))
+	if err != nil || string(data) != "2\n" {
+		t.Fatalf("drift = %q %v", data, err)
+	}
+}
+
+func TestAddResetsRecheckTimes(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, "db1"), 0775)
+	tb := &fakeTable{}
+	opt := &model.Options{BaseDir: dir, WatchLag: 60, MaxRecheckTimes: 2}
+	w := NewWatcher(&fakeDatabase{tables: map[string]*fakeTable{"t1": tb}}, nil, opt, "db1")
+
+	w.Add(&model.Change{Table: "t1", Key: "1"})
+	w.RecheckDue(time.Now().Add(61 * time.Second))
+	if w.pending["t1"]["1"].times != 1 {
+		t.Fatalf("pending = %v", w.pending)
+	}
+
+	//再次变更后重新计算延迟窗口和复核次数，不会被误报为不一致
+	w.Add(&model.Change{Table: "t1", Key: "1"})
+	w.RecheckDue(time.Now().Add(61 * time.Second))
+	if p := w.pending["t1"]["1"]; p == nil || p.times != 1 {
+		t.Fatalf("pending = %v", w.pending)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "db1", "t1.drift")); err == nil {
+		t.Fatal("unexpected drift file")
+	}
+}
+
+// fakeSource 按顺序返回变更和错误，记录Commit的调用
+type fakeSource struct {
+	items  []any
+	events *[]string
+}
+
+func (self *fakeSource) Next() (*model.Change, error) {
+	if len(self.items) == 0 {
+		return nil, io.EOF
+	}
+	item := self.items[0]
+	self.items = self.items[1:]
+	if err, ok := item.(error); ok {
+		return nil, err
+	}
+	return item.(*model.Change), nil
+}
+
+func (self *fakeSource) Close() error { return nil }
+
+func (self *fakeSource) Commit() error {
+	*self.events = append(*self.events, "commit")
+	return nil
+}
+
+func TestRunFlushAndCommit(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, "db1"), 0775)
+	var events []string
+	tb := &fakeTable{pass: map[model.Key]bool{"1": true}, events: &events}
+	src := &fakeSource{
+		items: []any{
+			&model.Change{Table: "t1", Key: "1"},
+			model.ErrCommitRequired,
+			&model.Change{Table: "t1", Key: "2"},
+		},
+		events: &events,
+	}
+	opt := &model.Options{BaseDir: dir, WatchLag: 0, WatchInterval: 1, MaxRecheckTimes: 3}
+	w := NewWatcher(&fakeDatabase{tables: map[string]*fakeTable{"t1": tb}}, src, opt, "db1")
+	w.Run()
+
+	//第一批复核通过后才确认消费，变更流结束时复核剩余的主键并报告不一致
+	want := []string{"[1]", "commit", "[2]", "commit"}
+	if !reflect.DeepEqual(events, want) {
+		t.Fatalf("events = %v, want %v", events, want)
+	}
+	if len(w.pending) != 0 {
+		t.Fatalf("pending = %v", w.pending)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "db1", "t1.drift"))
+	if err != nil || string(data) != "2\n" {
+		t.Fatalf("drift = %q %v", data, err)
+	}
+}